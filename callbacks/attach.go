@@ -0,0 +1,50 @@
+package callbacks
+
+import (
+	"fmt"
+
+	"github.com/weibaohui/kom/kom"
+	"k8s.io/klog/v2"
+)
+
+// Attach attaches to the main process of a container through the pods/attach subresource
+func Attach(k *kom.Kubectl) error {
+	stmt := k.Statement
+	ns := stmt.Namespace
+	name := stmt.Name
+	containerName := stmt.ContainerName
+
+	if stmt.ContainerName == "" {
+		return fmt.Errorf("Please call ContainerName() method to set Pod container name")
+	}
+	klog.V(8).Infof("Attach to [%s/%s:%s]\n", ns, name, containerName)
+
+	req := k.Client().CoreV1().RESTClient().
+		Post().
+		Namespace(ns).
+		Resource("pods").
+		Name(name).
+		SubResource("attach").
+		Param("container", containerName)
+
+	if stmt.Terminal != nil {
+		return streamTerminal(k, req, stmt.Terminal)
+	}
+
+	req.Param("tty", "false").
+		Param("stdin", fmt.Sprintf("%v", stmt.Stdin != nil)).
+		Param("stdout", "true").
+		Param("stderr", "true")
+
+	executor, err := createExecutor(req.URL(), k.RestConfig())
+	if err != nil {
+		return fmt.Errorf("error creating executor: %v", err)
+	}
+
+	err = streamLines(k, executor, "Attach")
+	if err != nil {
+		klog.V(8).Infof("Error attaching: %v", err)
+		return fmt.Errorf("error attaching to %s/%s:%s: %v", ns, name, containerName, err)
+	}
+	return nil
+}
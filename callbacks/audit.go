@@ -0,0 +1,337 @@
+package callbacks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weibaohui/kom/kom"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+)
+
+const (
+	auditCallbackName = "kom:audit"
+	auditSnapshotName = "kom:audit:snapshot"
+	auditSnapshotKey  = "kom:audit:snapshot"
+)
+
+// auditedOperations are the mutating processors that are audited
+var auditedOperations = []string{"create", "update", "patch", "delete", "exec", "stream-exec", "attach"}
+
+// AuditEvent is a single audited operation, written as one JSON line
+type AuditEvent struct {
+	Time         time.Time       `json:"time"`
+	Cluster      string          `json:"cluster"`
+	Operation    string          `json:"operation"`
+	Group        string          `json:"group,omitempty"`
+	Version      string          `json:"version,omitempty"`
+	Kind         string          `json:"kind,omitempty"`
+	Namespace    string          `json:"namespace,omitempty"`
+	Name         string          `json:"name,omitempty"`
+	Caller       string          `json:"caller,omitempty"`      // Identity from the request context
+	Impersonate  string          `json:"impersonate,omitempty"` // User the operation was impersonated as
+	PatchType    string          `json:"patchType,omitempty"`
+	Patch        string          `json:"patch,omitempty"`
+	Diff         []kom.FieldDiff `json:"diff,omitempty"` // Changes made by an update, requires WithAuditUpdateDiff
+	Container    string          `json:"container,omitempty"`
+	Command      string          `json:"command,omitempty"`
+	Args         []string        `json:"args,omitempty"`
+	ForceDelete  bool            `json:"forceDelete,omitempty"`
+	Evict        bool            `json:"evict,omitempty"` // The delete was an eviction, e.g. during Drain
+	Outcome      string          `json:"outcome"`         // success or failure
+	Error        string          `json:"error,omitempty"`
+	LatencyMs    float64         `json:"latencyMs"`
+	RowsAffected int64           `json:"rowsAffected"`
+}
+
+// AuditSink receives audit events
+type AuditSink interface {
+	Write(event *AuditEvent) error
+}
+
+// AuditSinkFunc adapts a function to an AuditSink
+type AuditSinkFunc func(event *AuditEvent) error
+
+func (f AuditSinkFunc) Write(event *AuditEvent) error {
+	return f(event)
+}
+
+// writerAuditSink writes events as JSON lines
+type writerAuditSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterAuditSink writes each event as a JSON line to w
+func NewWriterAuditSink(w io.Writer) AuditSink {
+	return &writerAuditSink{w: w}
+}
+
+func (s *writerAuditSink) Write(event *AuditEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(data)
+	return err
+}
+
+// FileAuditSink appends events as JSON lines to a file
+type FileAuditSink struct {
+	writerAuditSink
+	file *os.File
+}
+
+// NewFileAuditSink opens path for appending, the file is created with mode 0600
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit file %s error: %v", path, err)
+	}
+	return &FileAuditSink{writerAuditSink: writerAuditSink{w: f}, file: f}, nil
+}
+
+// Close closes the audit file
+func (s *FileAuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// NewChannelAuditSink sends events to ch
+// Events are dropped with an error when ch is full, so a slow reader never blocks operations.
+func NewChannelAuditSink(ch chan<- *AuditEvent) AuditSink {
+	return AuditSinkFunc(func(event *AuditEvent) error {
+		select {
+		case ch <- event:
+			return nil
+		default:
+			return fmt.Errorf("audit channel is full, event dropped")
+		}
+	})
+}
+
+type auditCallerKey struct{}
+
+// WithCaller stores the caller identity in ctx, it is recorded in audit events
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, auditCallerKey{}, caller)
+}
+
+// CallerFromContext returns the caller identity stored by WithCaller
+func CallerFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	caller, _ := ctx.Value(auditCallerKey{}).(string)
+	return caller
+}
+
+type auditOptions struct {
+	callerFunc func(ctx context.Context) string
+	updateDiff bool
+}
+
+// AuditOption configures RegisterAudit
+type AuditOption func(*auditOptions)
+
+// WithAuditCaller resolves the caller identity from the request context, replacing CallerFromContext
+func WithAuditCaller(fn func(ctx context.Context) string) AuditOption {
+	return func(o *auditOptions) {
+		o.callerFunc = fn
+	}
+}
+
+// WithAuditUpdateDiff records the fields changed by updates
+// The live object is read before every update, which costs one extra request.
+func WithAuditUpdateDiff() AuditOption {
+	return func(o *auditOptions) {
+		o.updateDiff = true
+	}
+}
+
+// RegisterAudit audits create, update, patch, delete, exec, stream-exec and attach on every cluster
+// Events are written to sink after the operation finished, whether it succeeded or failed.
+//
+// Example:
+// sink, _ := callbacks.NewFileAuditSink("/var/log/kom-audit.jsonl")
+// callbacks.RegisterAudit(sink)
+// kom.DefaultCluster().WithContext(callbacks.WithCaller(ctx, "alice")).Resource(&pod).Delete()
+func RegisterAudit(sink AuditSink, opts ...AuditOption) error {
+	o := &auditOptions{callerFunc: CallerFromContext}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var errs []error
+	for _, op := range auditedOperations {
+		operation := op
+		err := kom.GlobalCallback().Processor(operation).Phase(kom.PhaseFinally).Register(auditCallbackName, func(k *kom.Kubectl) error {
+			if err := sink.Write(newAuditEvent(k, operation, o)); err != nil {
+				klog.Errorf("write audit event of %s on cluster %s error: %v", operation, k.ID, err)
+			}
+			return nil
+		})
+		errs = append(errs, err)
+	}
+	if o.updateDiff {
+		errs = append(errs, kom.GlobalCallback().Update().Phase(kom.PhaseBefore).Register(auditSnapshotName, auditSnapshot))
+	}
+	return errors.Join(errs...)
+}
+
+// UnregisterAudit stops auditing on every cluster
+func UnregisterAudit() error {
+	var errs []error
+	for _, op := range auditedOperations {
+		errs = append(errs, kom.GlobalCallback().Processor(op).Remove(auditCallbackName))
+	}
+	errs = append(errs, kom.GlobalCallback().Update().Remove(auditSnapshotName))
+	return errors.Join(errs...)
+}
+
+// auditSnapshot keeps the live object before an update, to diff it afterwards
+func auditSnapshot(k *kom.Kubectl) error {
+	stmt := k.Statement
+	ns, name := targetOf(stmt)
+	if name == "" || stmt.GVR.Empty() {
+		return nil
+	}
+	var live *unstructured.Unstructured
+	var err error
+	if stmt.Namespaced {
+		if ns == "" {
+			ns = metav1.NamespaceDefault
+		}
+		live, err = k.DynamicClient().Resource(stmt.GVR).Namespace(ns).Get(stmt.Context, name, metav1.GetOptions{})
+	} else {
+		live, err = k.DynamicClient().Resource(stmt.GVR).Get(stmt.Context, name, metav1.GetOptions{})
+	}
+	if err != nil {
+		// Auditing never blocks the update
+		klog.V(4).Infof("audit snapshot of %s/%s error: %v", ns, name, err)
+		return nil
+	}
+	stmt.Settings.Store(auditSnapshotKey, live)
+	return nil
+}
+
+func newAuditEvent(k *kom.Kubectl, operation string, o *auditOptions) *AuditEvent {
+	stmt := k.Statement
+	event := &AuditEvent{
+		Time:         stmt.StartTime,
+		Cluster:      k.ID,
+		Operation:    operation,
+		Group:        stmt.GVK.Group,
+		Version:      stmt.GVK.Version,
+		Kind:         stmt.GVK.Kind,
+		Outcome:      "success",
+		LatencyMs:    float64(stmt.Duration.Microseconds()) / 1000,
+		RowsAffected: stmt.RowsAffected,
+	}
+	if o.callerFunc != nil {
+		event.Caller = o.callerFunc(stmt.Context)
+	}
+	if stmt.Impersonation != nil {
+		event.Impersonate = stmt.Impersonation.UserName
+	}
+	if k.Error != nil {
+		event.Outcome = "failure"
+		event.Error = k.Error.Error()
+	}
+
+	// Created objects may only carry their name in the object itself
+	event.Namespace, event.Name = targetOf(stmt)
+
+	switch operation {
+	case "patch":
+		event.PatchType = string(stmt.PatchType)
+		event.Patch = stmt.PatchData
+		if stmt.GVK.Kind == "Secret" || (stmt.GVR.Group == "" && stmt.GVR.Resource == "secrets") {
+			event.Patch = redactSecretPatch(stmt.PatchData)
+		}
+	case "update":
+		if v, ok := stmt.Settings.Load(auditSnapshotKey); ok && k.Error == nil {
+			event.Diff = auditDiff(v.(*unstructured.Unstructured), stmt.Dest)
+		}
+	case "delete":
+		event.ForceDelete = stmt.ForceDelete
+		event.Evict = stmt.Evict
+	case "exec", "stream-exec":
+		event.Container = stmt.ContainerName
+		event.Command = stmt.Command
+		event.Args = stmt.Args
+	case "attach":
+		event.Container = stmt.ContainerName
+	}
+	return event
+}
+
+// redactSecretPatch replaces the values a patch writes to data or stringData of a Secret by their hash
+// Patches that cannot be parsed are replaced by the hash of the whole patch.
+func redactSecretPatch(patch string) string {
+	var merge map[string]interface{}
+	if err := json.Unmarshal([]byte(patch), &merge); err == nil {
+		for _, field := range []string{"data", "stringData"} {
+			if data, ok := merge[field].(map[string]interface{}); ok {
+				secretHash(data)
+			}
+		}
+		if b, err := json.Marshal(merge); err == nil {
+			return string(b)
+		}
+	}
+	var ops []map[string]interface{}
+	if err := json.Unmarshal([]byte(patch), &ops); err == nil {
+		for _, op := range ops {
+			p, _ := op["path"].(string)
+			if _, ok := op["value"]; ok && (p == "/data" || p == "/stringData" || strings.HasPrefix(p, "/data/") || strings.HasPrefix(p, "/stringData/")) {
+				op["value"] = secretHash(op["value"])
+			}
+		}
+		if b, err := json.Marshal(ops); err == nil {
+			return string(b)
+		}
+	}
+	return secretHash(patch).(string)
+}
+
+// secretHash hashes a Secret value, or each value of a whole data map
+func secretHash(v interface{}) interface{} {
+	if m, ok := v.(map[string]interface{}); ok {
+		for k, value := range m {
+			m[k] = secretHash(value)
+		}
+		return m
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(fmt.Sprint(v))))
+}
+
+// auditDiff compares the live object before an update with the updated object
+func auditDiff(before *unstructured.Unstructured, dest interface{}) []kom.FieldDiff {
+	var after *unstructured.Unstructured
+	switch obj := dest.(type) {
+	case *unstructured.Unstructured:
+		after = obj
+	default:
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(dest)
+		if err != nil {
+			return nil
+		}
+		after = &unstructured.Unstructured{Object: content}
+	}
+	return kom.DiffObjects(before, after)
+}
@@ -47,6 +47,12 @@ func RegisterDefaultCallbacks(c *kom.ClusterInst) func() {
 	streamExecCallback := k.Callback().StreamExec()
 	_ = streamExecCallback.Register("kom:pod:stream:exec", StreamExecuteCommand)
 
+	attachCallback := k.Callback().Attach()
+	_ = attachCallback.Register("kom:pod:attach", Attach)
+
+	portForwardCallback := k.Callback().PortForward()
+	_ = portForwardCallback.Register("kom:pod:port-forward", PortForward)
+
 	logsCallback := k.Callback().Logs()
 	_ = logsCallback.Register("kom:pod:logs", GetLogs)
 
@@ -30,9 +30,9 @@ func Create(k *kom.Kubectl) error {
 			ns = metav1.NamespaceDefault
 			unstructuredObj.SetNamespace(ns)
 		}
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Create(ctx, unstructuredObj, metav1.CreateOptions{})
+		res, err = k.DynamicClient().Resource(gvr).Namespace(ns).Create(ctx, unstructuredObj, metav1.CreateOptions{})
 	} else {
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Create(ctx, unstructuredObj, metav1.CreateOptions{})
+		res, err = k.DynamicClient().Resource(gvr).Create(ctx, unstructuredObj, metav1.CreateOptions{})
 	}
 
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 
 	"github.com/weibaohui/kom/kom"
 	"github.com/weibaohui/kom/utils"
+	policyv1 "k8s.io/api/policy/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -30,14 +31,24 @@ func Delete(k *kom.Kubectl) error {
 		err = fmt.Errorf("Name must be specified when deleting an object")
 		return err
 	}
-	if namespaced {
+	if stmt.Evict {
+		// Evictions are only served for pods
+		if ns == "" {
+			ns = metav1.NamespaceDefault
+		}
+		eviction := &policyv1.Eviction{
+			ObjectMeta:    metav1.ObjectMeta{Name: name, Namespace: ns},
+			DeleteOptions: &deleteOptions,
+		}
+		err = k.Client().PolicyV1().Evictions(ns).Evict(ctx, eviction)
+	} else if namespaced {
 		if ns == "" {
 			ns = metav1.NamespaceDefault
 		}
 
-		err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Delete(ctx, name, deleteOptions)
+		err = k.DynamicClient().Resource(gvr).Namespace(ns).Delete(ctx, name, deleteOptions)
 	} else {
-		err = stmt.Kubectl.DynamicClient().Resource(gvr).Delete(ctx, name, deleteOptions)
+		err = k.DynamicClient().Resource(gvr).Delete(ctx, name, deleteOptions)
 	}
 
 	if err != nil {
@@ -2,15 +2,20 @@ package callbacks
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/weibaohui/kom/kom"
 	"k8s.io/apimachinery/pkg/util/httpstream"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/util/exec"
 	"k8s.io/klog/v2"
 )
 
@@ -31,12 +36,15 @@ func ExecuteCommand(k *kom.Kubectl) error {
 		return fmt.Errorf("Please call Command() method to set command")
 	}
 
-	// Reflection check
-	destValue := reflect.ValueOf(stmt.Dest)
+	result, isResult := stmt.Dest.(*kom.ExecResult)
+	if !isResult {
+		// Reflection check
+		destValue := reflect.ValueOf(stmt.Dest)
 
-	// Ensure dest is a pointer to a byte slice
-	if !(destValue.Kind() == reflect.Ptr && destValue.Elem().Kind() == reflect.Slice) || destValue.Elem().Type().Elem().Kind() != reflect.Uint8 {
-		return fmt.Errorf("Please ensure dest is a pointer to a byte slice. Define var s []byte and use &s")
+		// Ensure dest is a pointer to a byte slice
+		if !(destValue.Kind() == reflect.Ptr && destValue.Elem().Kind() == reflect.Slice) || destValue.Elem().Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("Please ensure dest is a pointer to a byte slice or a *kom.ExecResult. Define var s []byte and use &s")
+		}
 	}
 
 	var err error
@@ -67,18 +75,46 @@ func ExecuteCommand(k *kom.Kubectl) error {
 		return fmt.Errorf("error creating executor: %v", err)
 	}
 
-	var outBuf bytes.Buffer
-	var errBuf bytes.Buffer
+	outBuf := &limitedBuffer{limit: stmt.ExecOutputLimit}
+	errBuf := &limitedBuffer{limit: stmt.ExecOutputLimit}
 
+	var stdout io.Writer = outBuf
+	if stmt.Stdout != nil {
+		// Streamed to the caller, e.g. a file download, so it is not kept in memory
+		stdout = stmt.Stdout
+	}
 	options := &remotecommand.StreamOptions{
-		Stdout: &outBuf,
-		Stderr: &errBuf,
+		Stdout: stdout,
+		Stderr: errBuf,
 		Tty:    false,
 	}
 	if stmt.Stdin != nil {
 		options.Stdin = stmt.Stdin
 	}
+	if stmt.ExecTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, stmt.ExecTimeout)
+		defer cancel()
+	}
+	start := time.Now()
 	err = executor.StreamWithContext(ctx, *options)
+	if isResult {
+		result.Stdout = outBuf.Bytes()
+		result.Stderr = errBuf.Bytes()
+		result.Truncated = outBuf.truncated || errBuf.truncated
+		result.Duration = time.Since(start)
+		result.ExitCode = 0
+	}
+	if err != nil && stmt.ExecTimeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("command %s timed out after %s", command, stmt.ExecTimeout)
+	}
+	var exitErr exec.CodeExitError
+	if err != nil && isResult && errors.As(err, &exitErr) {
+		// The command ran, its failure is reported by the exit code
+		result.ExitCode = exitErr.ExitStatus()
+		klog.V(8).Infof("Execute %s exited with %d", command, result.ExitCode)
+		return nil
+	}
 	if err != nil {
 		s := errBuf.String()
 		klog.V(8).Infof("Error executing command: %v", err)
@@ -87,6 +123,10 @@ func ExecuteCommand(k *kom.Kubectl) error {
 		}
 		return fmt.Errorf("error executing command: %v %v", err, s)
 	}
+	if isResult {
+		klog.V(8).Infof("Execute result %s", result.Stdout)
+		return nil
+	}
 
 	// Write result to tx.Statement.Dest
 	if destBytes, ok := k.Statement.Dest.(*[]byte); ok {
@@ -99,6 +139,38 @@ func ExecuteCommand(k *kom.Kubectl) error {
 	return nil
 }
 
+// limitedBuffer keeps the first limit bytes written to it and discards the rest, 0 keeps all
+// The buffer is not embedded, so io.Copy cannot bypass Write through bytes.Buffer.ReadFrom.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if b.limit > 0 {
+		room := b.limit - b.buf.Len()
+		if room < len(p) {
+			b.truncated = true
+			if room <= 0 {
+				return n, nil
+			}
+			p = p[:room]
+		}
+	}
+	_, err := b.buf.Write(p)
+	return n, err
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
+
 func createExecutor(url *url.URL, config *rest.Config) (remotecommand.Executor, error) {
 
 	exec, err := remotecommand.NewSPDYExecutor(config, "POST", url)
@@ -29,17 +29,22 @@ func Get(k *kom.Kubectl) error {
 	}
 
 	cacheKey := fmt.Sprintf("%s/%s/%s/%s/%s", ns, name, gvr.Group, gvr.Resource, gvr.Version)
-	res, err := utils.GetOrSetCache(stmt.Kubectl.ClusterCache(), cacheKey, stmt.CacheTTL, func() (ret *unstructured.Unstructured, err error) {
+	if identity := k.Identity(); identity != "" {
+		// Results differ per identity, never share them across impersonated users
+		cacheKey = fmt.Sprintf("%s/%s", identity, cacheKey)
+	}
+	res, hit, err := utils.GetOrSetCacheHit(stmt.Kubectl.ClusterCache(), cacheKey, stmt.CacheTTL, func() (ret *unstructured.Unstructured, err error) {
 		if namespaced {
 			if ns == "" {
 				ns = metav1.NamespaceDefault
 			}
-			ret, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
+			ret, err = k.DynamicClient().Resource(gvr).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
 		} else {
-			ret, err = stmt.Kubectl.DynamicClient().Resource(gvr).Get(ctx, name, metav1.GetOptions{})
+			ret, err = k.DynamicClient().Resource(gvr).Get(ctx, name, metav1.GetOptions{})
 		}
 		return
 	})
+	stmt.SetCacheStatus(hit)
 	if err != nil {
 		return err
 	}
@@ -43,27 +43,32 @@ func List(k *kom.Kubectl) error {
 	elemType := destValue.Elem().Type().Elem()
 
 	cacheKey := fmt.Sprintf("%s/%s/%s/%s", ns, gvr.Group, gvr.Resource, gvr.Version)
-	list, err := utils.GetOrSetCache(stmt.ClusterCache(), cacheKey, stmt.CacheTTL, func() (list *unstructured.UnstructuredList, err error) {
+	if identity := k.Identity(); identity != "" {
+		// Results differ per identity, never share them across impersonated users
+		cacheKey = fmt.Sprintf("%s/%s", identity, cacheKey)
+	}
+	list, hit, err := utils.GetOrSetCacheHit(stmt.ClusterCache(), cacheKey, stmt.CacheTTL, func() (list *unstructured.UnstructuredList, err error) {
 		// TODO Change list retrieval to use Option to solve large data volume retrieval issues
 		if namespaced {
 			if stmt.AllNamespace || len(namespaceList) > 1 {
 				// All namespaces or multiple namespaces provided
 				// client-go doesn't support cross-namespace queries, so get all and filter later
 				ns = metav1.NamespaceAll
-				list, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).List(ctx, listOptions)
+				list, err = k.DynamicClient().Resource(gvr).Namespace(ns).List(ctx, listOptions)
 			} else {
 				// Not all namespaces and no multiple namespaces provided
 				if ns == "" {
 					ns = metav1.NamespaceDefault
 				}
-				list, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).List(ctx, listOptions)
+				list, err = k.DynamicClient().Resource(gvr).Namespace(ns).List(ctx, listOptions)
 			}
 		} else {
 			// Cluster-level query, no namespace needed
-			list, err = stmt.Kubectl.DynamicClient().Resource(gvr).List(ctx, listOptions)
+			list, err = k.DynamicClient().Resource(gvr).List(ctx, listOptions)
 		}
 		return
 	})
+	stmt.SetCacheStatus(hit)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,286 @@
+package callbacks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/weibaohui/kom/kom"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+)
+
+const metricsCallbackName = "kom:metrics"
+
+// allOperations are all processors measured by Metrics
+var allOperations = []string{"get", "list", "create", "update", "patch", "delete", "exec", "stream-exec", "attach", "port-forward", "logs", "watch", "describe"}
+
+var (
+	// latencyBuckets are the upper bounds of the request duration histogram, in seconds
+	latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	// rowsBuckets are the upper bounds of the result size histogram, in rows
+	rowsBuckets = []float64{0, 1, 10, 100, 1000, 10000}
+)
+
+// Metrics collects per-cluster, per-operation counters and histograms
+// Register it to measure every cluster, then expose it in the Prometheus text format through WriteTo or ServeHTTP.
+type Metrics struct {
+	mu     sync.Mutex
+	series map[metricsKey]*metricsSeries
+}
+
+type metricsKey struct {
+	cluster   string
+	operation string
+}
+
+type metricsSeries struct {
+	requests    uint64
+	errors      map[string]uint64 // Errors by reason
+	cacheHits   uint64
+	cacheMisses uint64
+	latency     histogram
+	rows        histogram
+}
+
+type histogram struct {
+	counts []uint64 // Per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(buckets []float64, v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(buckets))
+	}
+	for i, le := range buckets {
+		if v <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// NewMetrics creates an empty metrics collector
+func NewMetrics() *Metrics {
+	return &Metrics{series: map[metricsKey]*metricsSeries{}}
+}
+
+var (
+	// activeMetrics is the registered Metrics, only one can be registered at a time
+	activeMetrics atomic.Pointer[Metrics]
+	// metricsForgetOnce installs forgetOnClose for clusters registered later
+	metricsForgetOnce sync.Once
+	// metricsForgetHooked are the clusters whose close already forgets their values
+	metricsForgetHooked sync.Map
+)
+
+// Register measures every operation on every cluster
+// Only one Metrics can be registered at a time, registering another one fails until this one is unregistered.
+// The values of a cluster are forgotten when it is removed or closed.
+func (m *Metrics) Register() error {
+	if !activeMetrics.CompareAndSwap(nil, m) {
+		if activeMetrics.Load() == m {
+			return nil
+		}
+		return fmt.Errorf("another Metrics is already registered, unregister it first")
+	}
+	metricsForgetOnce.Do(func() {
+		kom.Clusters().AddRegisterCallbackFunc(func(cluster *kom.ClusterInst) func() {
+			forgetOnClose(cluster)
+			return nil
+		})
+	})
+	for _, cluster := range kom.Clusters().AllClusters() {
+		forgetOnClose(cluster)
+	}
+
+	var errs []error
+	for _, op := range allOperations {
+		operation := op
+		errs = append(errs, kom.GlobalCallback().Processor(operation).Phase(kom.PhaseFinally).Register(metricsCallbackName, func(k *kom.Kubectl) error {
+			m.observe(k, operation)
+			return nil
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// Unregister stops measuring, collected values are kept
+func (m *Metrics) Unregister() error {
+	if !activeMetrics.CompareAndSwap(m, nil) {
+		return nil
+	}
+	var errs []error
+	for _, op := range allOperations {
+		errs = append(errs, kom.GlobalCallback().Processor(op).Remove(metricsCallbackName))
+	}
+	return errors.Join(errs...)
+}
+
+// forgetOnClose drops the values of the cluster from the registered Metrics once the cluster is closed
+func forgetOnClose(cluster *kom.ClusterInst) {
+	if _, hooked := metricsForgetHooked.LoadOrStore(cluster, struct{}{}); hooked {
+		return
+	}
+	cluster.OnClose(func() {
+		metricsForgetHooked.Delete(cluster)
+		if m := activeMetrics.Load(); m != nil {
+			m.Forget(cluster.ID)
+		}
+	})
+}
+
+// Forget drops the values of a cluster, e.g. after it was removed
+func (m *Metrics) Forget(cluster string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.series {
+		if key.cluster == cluster {
+			delete(m.series, key)
+		}
+	}
+}
+
+func (m *Metrics) observe(k *kom.Kubectl, operation string) {
+	if cluster := kom.Clusters().GetClusterById(k.ID); cluster == nil || cluster.Closed() {
+		// Ended after the cluster was removed, its values are already forgotten
+		return
+	}
+	stmt := k.Statement
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := metricsKey{cluster: k.ID, operation: operation}
+	s, ok := m.series[key]
+	if !ok {
+		s = &metricsSeries{errors: map[string]uint64{}}
+		m.series[key] = s
+	}
+	s.requests++
+	s.latency.observe(latencyBuckets, stmt.Duration.Seconds())
+	if k.Error != nil {
+		s.errors[errorReason(k.Error)]++
+	} else {
+		s.rows.observe(rowsBuckets, float64(stmt.RowsAffected))
+	}
+	switch stmt.CacheStatus {
+	case kom.CacheHit:
+		s.cacheHits++
+	case kom.CacheMiss:
+		s.cacheMisses++
+	}
+}
+
+// errorReason classifies an error for the reason label
+func errorReason(err error) string {
+	if reason := apierrors.ReasonForError(err); reason != "" {
+		return string(reason)
+	}
+	switch {
+	case IsPolicyDenied(err):
+		return "PolicyDenied"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "Timeout"
+	case errors.Is(err, context.Canceled):
+		return "Canceled"
+	}
+	return "Unknown"
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	keys := make([]metricsKey, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].cluster != keys[j].cluster {
+			return keys[i].cluster < keys[j].cluster
+		}
+		return keys[i].operation < keys[j].operation
+	})
+
+	var b strings.Builder
+	b.WriteString("# HELP kom_requests_total Operations executed per cluster and operation.\n")
+	b.WriteString("# TYPE kom_requests_total counter\n")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "kom_requests_total{%s} %d\n", key.labels(), m.series[key].requests)
+	}
+
+	b.WriteString("# HELP kom_request_errors_total Failed operations per cluster, operation and reason.\n")
+	b.WriteString("# TYPE kom_request_errors_total counter\n")
+	for _, key := range keys {
+		errs := m.series[key].errors
+		reasons := make([]string, 0, len(errs))
+		for reason := range errs {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			fmt.Fprintf(&b, "kom_request_errors_total{%s,reason=%q} %d\n", key.labels(), reason, errs[reason])
+		}
+	}
+
+	b.WriteString("# HELP kom_cache_requests_total Cached lookups per cluster, operation and result.\n")
+	b.WriteString("# TYPE kom_cache_requests_total counter\n")
+	for _, key := range keys {
+		s := m.series[key]
+		if s.cacheHits+s.cacheMisses == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "kom_cache_requests_total{%s,result=%q} %d\n", key.labels(), kom.CacheHit, s.cacheHits)
+		fmt.Fprintf(&b, "kom_cache_requests_total{%s,result=%q} %d\n", key.labels(), kom.CacheMiss, s.cacheMisses)
+	}
+
+	b.WriteString("# HELP kom_request_duration_seconds Operation latency per cluster and operation.\n")
+	b.WriteString("# TYPE kom_request_duration_seconds histogram\n")
+	for _, key := range keys {
+		writeHistogram(&b, "kom_request_duration_seconds", key.labels(), latencyBuckets, &m.series[key].latency)
+	}
+
+	b.WriteString("# HELP kom_result_rows Rows returned or affected by successful operations.\n")
+	b.WriteString("# TYPE kom_result_rows histogram\n")
+	for _, key := range keys {
+		writeHistogram(&b, "kom_result_rows", key.labels(), rowsBuckets, &m.series[key].rows)
+	}
+	m.mu.Unlock()
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP serves the metrics, so Metrics can be mounted as a /metrics handler
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := m.WriteTo(w); err != nil {
+		klog.V(4).Infof("write metrics error: %v", err)
+	}
+}
+
+func (key metricsKey) labels() string {
+	return fmt.Sprintf("cluster=%q,operation=%q", key.cluster, key.operation)
+}
+
+func writeHistogram(b *strings.Builder, name, labels string, buckets []float64, h *histogram) {
+	if h.count == 0 {
+		return
+	}
+	var cumulative uint64
+	for i, le := range buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(b, "%s_bucket{%s,le=\"%g\"} %d\n", name, labels, le, cumulative)
+	}
+	fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(b, "%s_sum{%s} %g\n", name, labels, h.sum)
+	fmt.Fprintf(b, "%s_count{%s} %d\n", name, labels, h.count)
+}
@@ -20,6 +20,10 @@ func Patch(k *kom.Kubectl) error {
 	ctx := stmt.Context
 	patchType := stmt.PatchType
 	patchData := stmt.PatchData
+	var subresources []string
+	if stmt.SubResource != "" {
+		subresources = append(subresources, stmt.SubResource)
+	}
 
 	var res *unstructured.Unstructured
 	var err error
@@ -31,9 +35,9 @@ func Patch(k *kom.Kubectl) error {
 		if ns == "" {
 			ns = metav1.NamespaceDefault
 		}
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Patch(ctx, name, patchType, []byte(patchData), metav1.PatchOptions{})
+		res, err = k.DynamicClient().Resource(gvr).Namespace(ns).Patch(ctx, name, patchType, []byte(patchData), metav1.PatchOptions{}, subresources...)
 	} else {
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Patch(ctx, name, patchType, []byte(patchData), metav1.PatchOptions{})
+		res, err = k.DynamicClient().Resource(gvr).Patch(ctx, name, patchType, []byte(patchData), metav1.PatchOptions{}, subresources...)
 	}
 	if err != nil {
 		return err
@@ -0,0 +1,207 @@
+package callbacks
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sync/atomic"
+
+	"github.com/weibaohui/kom/kom"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+const policyCallbackName = "kom:policy"
+
+// policedOperations are the processors checked by the policy
+var policedOperations = []string{"create", "update", "patch", "delete", "exec", "stream-exec", "attach", "port-forward"}
+
+// PolicyRule denies the operations it matches
+// Every non-empty field must match. Names support shell patterns such as "prod-*".
+//
+// Examples:
+// read-only cluster:        {clusters: [prod-*]}
+// no deletes in kube-system: {operations: [delete], namespaces: [kube-system]}
+// exec only in dev:         {operations: [exec, stream-exec, attach], exceptNamespaces: [dev]}
+// no port forwards:         {operations: [port-forward]}
+// no force delete:          {operations: [delete], forceDelete: true}
+type PolicyRule struct {
+	Name             string   `json:"name"`
+	Message          string   `json:"message,omitempty"`          // Returned to the caller when denied
+	Clusters         []string `json:"clusters,omitempty"`         // Cluster IDs
+	ClusterSelector  string   `json:"clusterSelector,omitempty"`  // Label selector on cluster labels
+	Operations       []string `json:"operations,omitempty"`       // Defaults to all mutating operations
+	Namespaces       []string `json:"namespaces,omitempty"`       // Only these namespaces
+	ExceptNamespaces []string `json:"exceptNamespaces,omitempty"` // All namespaces but these
+	Kinds            []string `json:"kinds,omitempty"`
+	ForceDelete      bool     `json:"forceDelete,omitempty"` // Only force deletes
+
+	selector labels.Selector
+}
+
+// Policy is a set of deny rules checked before every mutating operation
+type Policy struct {
+	Rules []PolicyRule `json:"rules"`
+}
+
+// PolicyDeniedError is returned when a rule denies an operation
+type PolicyDeniedError struct {
+	Rule    string
+	Message string
+}
+
+func (e *PolicyDeniedError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("denied by policy %s: %s", e.Rule, e.Message)
+	}
+	return fmt.Sprintf("denied by policy %s", e.Rule)
+}
+
+// IsPolicyDenied reports whether err was caused by a policy rule
+func IsPolicyDenied(err error) bool {
+	var denied *PolicyDeniedError
+	return errors.As(err, &denied)
+}
+
+// LoadPolicyFile reads a policy from a YAML or JSON file
+func LoadPolicyFile(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read policy file %s error: %v", file, err)
+	}
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parse policy file %s error: %v", file, err)
+	}
+	return &p, nil
+}
+
+// activePolicy is the policy checked by the registered callbacks
+var activePolicy atomic.Pointer[Policy]
+
+// RegisterPolicy checks the policy before every mutating operation on every cluster
+// This covers chain calls, Ctl() helpers such as Scale, Label or Drain, and the Applier.
+// Calling it again swaps the rules in place, a nil policy stops checking like UnregisterPolicy.
+func RegisterPolicy(p *Policy) error {
+	if p == nil {
+		return UnregisterPolicy()
+	}
+	if err := p.compile(); err != nil {
+		return err
+	}
+	if activePolicy.Swap(p) != nil {
+		return nil
+	}
+	var errs []error
+	for _, op := range policedOperations {
+		operation := op
+		errs = append(errs, kom.GlobalCallback().Processor(operation).Phase(kom.PhaseBefore).Before("*").Register(policyCallbackName, func(k *kom.Kubectl) error {
+			if p := activePolicy.Load(); p != nil {
+				return p.Check(k, operation)
+			}
+			return nil
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// UnregisterPolicy stops checking the policy
+func UnregisterPolicy() error {
+	if activePolicy.Swap(nil) == nil {
+		return nil
+	}
+	var errs []error
+	for _, op := range policedOperations {
+		errs = append(errs, kom.GlobalCallback().Processor(op).Remove(policyCallbackName))
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Policy) compile() error {
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.ClusterSelector == "" {
+			continue
+		}
+		sel, err := labels.Parse(r.ClusterSelector)
+		if err != nil {
+			return fmt.Errorf("policy %s has invalid cluster selector %q: %v", r.Name, r.ClusterSelector, err)
+		}
+		r.selector = sel
+	}
+	return nil
+}
+
+// Check returns a PolicyDeniedError when a rule denies the operation
+func (p *Policy) Check(k *kom.Kubectl, operation string) error {
+	stmt := k.Statement
+	ns, _ := targetOf(stmt)
+	if ns == "" && stmt.Namespaced {
+		ns = metav1.NamespaceDefault
+	}
+	var clusterLabels labels.Set
+	if cluster := kom.Clusters().GetClusterById(k.ID); cluster != nil {
+		clusterLabels = cluster.Labels()
+	}
+
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if len(r.Clusters) > 0 && !matchPattern(r.Clusters, k.ID) {
+			continue
+		}
+		if r.selector != nil && !r.selector.Matches(clusterLabels) {
+			continue
+		}
+		if len(r.Operations) > 0 && !matchPattern(r.Operations, operation) {
+			continue
+		}
+		if len(r.Namespaces) > 0 && !matchPattern(r.Namespaces, ns) {
+			continue
+		}
+		if len(r.ExceptNamespaces) > 0 && matchPattern(r.ExceptNamespaces, ns) {
+			continue
+		}
+		if len(r.Kinds) > 0 && !matchPattern(r.Kinds, stmt.GVK.Kind) {
+			continue
+		}
+		if r.ForceDelete && !stmt.ForceDelete {
+			continue
+		}
+		return &PolicyDeniedError{Rule: r.Name, Message: r.Message}
+	}
+	return nil
+}
+
+// matchPattern reports whether value matches one of the shell patterns
+func matchPattern(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// targetOf returns the namespace and name of the operation target
+// Objects passed to Create or Update may only carry them in the object itself.
+func targetOf(stmt *kom.Statement) (ns string, name string) {
+	ns, name = stmt.Namespace, stmt.Name
+	if ns != "" && name != "" {
+		return
+	}
+	if obj, ok := stmt.Dest.(runtime.Object); ok {
+		if accessor, err := meta.Accessor(obj); err == nil {
+			if ns == "" {
+				ns = accessor.GetNamespace()
+			}
+			if name == "" {
+				name = accessor.GetName()
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,110 @@
+package callbacks
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/weibaohui/kom/kom"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+	"k8s.io/klog/v2"
+)
+
+// PortForward starts forwarding local ports to a pod and returns once they are listening
+func PortForward(k *kom.Kubectl) error {
+	stmt := k.Statement
+	ns := stmt.Namespace
+	name := stmt.Name
+	req := stmt.PortForward
+	ctx := stmt.Context
+
+	if req == nil {
+		return fmt.Errorf("Please call PortForward() method to set the ports")
+	}
+	if name == "" {
+		return fmt.Errorf("Name must be specified when forwarding ports")
+	}
+	if ns == "" {
+		ns = "default"
+	}
+
+	pfURL := k.Client().CoreV1().RESTClient().
+		Post().
+		Namespace(ns).
+		Resource("pods").
+		Name(name).
+		SubResource("portforward").
+		URL()
+
+	dialer, err := createDialer(pfURL, k.RestConfig())
+	if err != nil {
+		return fmt.Errorf("error creating port forward dialer: %v", err)
+	}
+
+	out := &klogWriter{prefix: fmt.Sprintf("port-forward %s/%s", ns, name)}
+	fw, err := portforward.NewOnAddresses(dialer, req.Addresses, req.Ports, req.StopCh, req.ReadyCh, out, out)
+	if err != nil {
+		return fmt.Errorf("error creating port forward: %v", err)
+	}
+	req.Forwarder = fw
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- fw.ForwardPorts()
+	}()
+
+	select {
+	case <-req.ReadyCh:
+	case err := <-errCh:
+		return fmt.Errorf("error forwarding ports %v to %s/%s: %v", req.Ports, ns, name, err)
+	case <-ctx.Done():
+		req.Stop()
+		return ctx.Err()
+	}
+	klog.V(8).Infof("Forwarding %v to %s/%s\n", req.Ports, ns, name)
+
+	// Stop forwarding when the cluster is closed or the operation context ends
+	go func() {
+		select {
+		case err := <-errCh:
+			req.DoneCh <- err
+			return
+		case <-ctx.Done():
+			req.Stop()
+		}
+		req.DoneCh <- <-errCh
+	}()
+	return nil
+}
+
+// createDialer prefers SPDY and falls back to websockets, like createExecutor
+func createDialer(url *url.URL, config *rest.Config) (httpstream.Dialer, error) {
+	transport, upgrader, err := spdy.RoundTripperFor(config)
+	if err != nil {
+		return nil, err
+	}
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", url)
+	tunnelingDialer, err := portforward.NewSPDYOverWebsocketDialer(url, config)
+	if err != nil {
+		return nil, err
+	}
+	return portforward.NewFallbackDialer(tunnelingDialer, dialer, func(err error) bool {
+		return httpstream.IsUpgradeFailure(err) || httpstream.IsHTTPSProxyError(err)
+	}), nil
+}
+
+// klogWriter logs the progress messages of the forwarder
+type klogWriter struct {
+	prefix string
+}
+
+func (w *klogWriter) Write(p []byte) (int, error) {
+	klog.V(6).Infof("%s: %s", w.prefix, p)
+	return len(p), nil
+}
+
+var _ io.Writer = &klogWriter{}
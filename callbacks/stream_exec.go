@@ -18,7 +18,6 @@ func StreamExecuteCommand(k *kom.Kubectl) error {
 	command := stmt.Command
 	args := stmt.Args
 	containerName := stmt.ContainerName
-	ctx := stmt.Context
 
 	if stmt.ContainerName == "" {
 		return fmt.Errorf("Please call ContainerName() method to set Pod container name")
@@ -45,6 +44,10 @@ func StreamExecuteCommand(k *kom.Kubectl) error {
 		req.Param("command", arg)
 	}
 
+	if stmt.Terminal != nil {
+		return streamTerminal(k, req, stmt.Terminal)
+	}
+
 	req.Param("tty", "false").
 		Param("stdin", fmt.Sprintf("%v", stmt.Stdin != nil)).
 		Param("stdout", "true").
@@ -55,6 +58,19 @@ func StreamExecuteCommand(k *kom.Kubectl) error {
 		return fmt.Errorf("error creating executor: %v", err)
 	}
 
+	// Start streaming execution
+	err = streamLines(k, executor, "StreamExecuteCommand")
+	if err != nil {
+		klog.V(8).Infof("Error Stream executing command: %v", err)
+		return fmt.Errorf("error Stream executing command: %v", err)
+	}
+
+	return nil
+}
+
+// streamLines runs executor and hands every line of its output to the stdout and stderr callbacks of the statement
+func streamLines(k *kom.Kubectl, executor remotecommand.Executor, source string) error {
+	stmt := k.Statement
 	// Use io.Pipe to implement real-time streaming of Stdout and Stderr
 	stdoutPr, stdoutPw := io.Pipe()
 	stderrPr, stderrPw := io.Pipe()
@@ -70,12 +86,12 @@ func StreamExecuteCommand(k *kom.Kubectl) error {
 			line := scanner.Text()
 			if stmt.StdoutCallback != nil {
 				if err := stmt.StdoutCallback([]byte(line)); err != nil {
-					klog.Errorf("StreamExecuteCommand Error in  Stdout callback: %v", err)
+					klog.Errorf("%s Error in Stdout callback: %v", source, err)
 				}
 			}
 		}
 		if err := scanner.Err(); err != nil {
-			klog.Errorf("StreamExecuteCommand Error reading from Stdout pipe: %v", err)
+			klog.Errorf("%s Error reading from Stdout pipe: %v", source, err)
 		}
 	}()
 
@@ -86,12 +102,12 @@ func StreamExecuteCommand(k *kom.Kubectl) error {
 			line := scanner.Text()
 			if stmt.StderrCallback != nil {
 				if err := stmt.StderrCallback([]byte(line)); err != nil {
-					klog.Errorf("StreamExecuteCommand Error in Stderr callback: %v", err)
+					klog.Errorf("%s Error in Stderr callback: %v", source, err)
 				}
 			}
 		}
 		if err := scanner.Err(); err != nil {
-			klog.Errorf("StreamExecuteCommand Error reading from Stderr pipe: %v", err)
+			klog.Errorf("%s Error reading from Stderr pipe: %v", source, err)
 		}
 	}()
 
@@ -103,13 +119,5 @@ func StreamExecuteCommand(k *kom.Kubectl) error {
 	if stmt.Stdin != nil {
 		options.Stdin = stmt.Stdin
 	}
-
-	// Start streaming execution
-	err = executor.StreamWithContext(ctx, *options)
-	if err != nil {
-		klog.V(8).Infof("Error Stream executing command: %v", err)
-		return fmt.Errorf("error Stream executing command: %v", err)
-	}
-
-	return nil
+	return executor.StreamWithContext(stmt.Context, *options)
 }
@@ -0,0 +1,52 @@
+package callbacks
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/weibaohui/kom/kom"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/util/exec"
+	"k8s.io/klog/v2"
+)
+
+// streamTerminal connects term to the exec request and waits for the remote process to exit
+func streamTerminal(k *kom.Kubectl, req *rest.Request, term *kom.Terminal) error {
+	stmt := k.Statement
+	useStderr := term.Stderr != nil && !term.TTY
+
+	req.Param("tty", fmt.Sprintf("%v", term.TTY)).
+		Param("stdin", fmt.Sprintf("%v", term.Stdin != nil)).
+		Param("stdout", fmt.Sprintf("%v", term.Stdout != nil)).
+		Param("stderr", fmt.Sprintf("%v", useStderr))
+
+	executor, err := createExecutor(req.URL(), k.RestConfig())
+	if err != nil {
+		return fmt.Errorf("error creating executor: %v", err)
+	}
+
+	options := remotecommand.StreamOptions{
+		Stdin:             term.Stdin,
+		Stdout:            term.Stdout,
+		Tty:               term.TTY,
+		TerminalSizeQueue: term.SizeQueue,
+	}
+	if useStderr {
+		options.Stderr = term.Stderr
+	}
+
+	err = executor.StreamWithContext(stmt.Context, options)
+	var exitErr exec.CodeExitError
+	switch {
+	case err == nil:
+		term.Exited, term.ExitCode = true, 0
+	case errors.As(err, &exitErr):
+		term.Exited, term.ExitCode = true, exitErr.ExitStatus()
+	default:
+		klog.V(8).Infof("Error in terminal of %s/%s:%s: %v", stmt.Namespace, stmt.Name, stmt.ContainerName, err)
+		return fmt.Errorf("error streaming terminal: %v", err)
+	}
+	klog.V(8).Infof("Terminal of %s/%s:%s exited with %d", stmt.Namespace, stmt.Name, stmt.ContainerName, term.ExitCode)
+	return nil
+}
@@ -32,9 +32,9 @@ func Update(k *kom.Kubectl) error {
 			ns = metav1.NamespaceDefault
 		}
 		unstructuredObj.SetNamespace(ns)
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Update(ctx, unstructuredObj, metav1.UpdateOptions{})
+		res, err = k.DynamicClient().Resource(gvr).Namespace(ns).Update(ctx, unstructuredObj, metav1.UpdateOptions{})
 	} else {
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Update(ctx, unstructuredObj, metav1.UpdateOptions{})
+		res, err = k.DynamicClient().Resource(gvr).Update(ctx, unstructuredObj, metav1.UpdateOptions{})
 	}
 
 	if err != nil {
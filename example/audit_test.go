@@ -0,0 +1,148 @@
+package example
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weibaohui/kom/callbacks"
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+)
+
+func TestAuditFailedDelete(t *testing.T) {
+	_, err := kom.Clusters().RegisterByConfigWithID(&rest.Config{Host: "https://127.0.0.1:1", Timeout: time.Second}, "audit-test", kom.WithLazyInit())
+	if err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	defer kom.Clusters().RemoveClusterById("audit-test")
+
+	events := make(chan *callbacks.AuditEvent, 10)
+	if err := callbacks.RegisterAudit(callbacks.NewChannelAuditSink(events)); err != nil {
+		t.Fatalf("register audit error: %v", err)
+	}
+	defer callbacks.UnregisterAudit()
+
+	ctx := callbacks.WithCaller(context.Background(), "alice")
+	var pod corev1.Pod
+	err = kom.Cluster("audit-test").WithContext(ctx).Resource(&pod).Namespace("default").Name("nginx").ForceDelete().Error
+	if err == nil {
+		t.Fatalf("delete on an unreachable cluster should fail")
+	}
+
+	select {
+	case event := <-events:
+		if event.Operation != "delete" || event.Cluster != "audit-test" || event.Name != "nginx" || event.Namespace != "default" {
+			t.Fatalf("unexpected event %+v", event)
+		}
+		if event.Caller != "alice" || event.Outcome != "failure" || event.Error == "" || !event.ForceDelete {
+			t.Fatalf("unexpected event %+v", event)
+		}
+	default:
+		t.Fatalf("failed delete should be audited")
+	}
+}
+
+func TestAuditRedactsSecretPatch(t *testing.T) {
+	server := newFakeAPIServer(t)
+	server.add("secrets", &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "token"}})
+	k := server.register(t, "audit-secret")
+
+	events := make(chan *callbacks.AuditEvent, 10)
+	if err := callbacks.RegisterAudit(callbacks.NewChannelAuditSink(events)); err != nil {
+		t.Fatalf("register audit error: %v", err)
+	}
+	defer callbacks.UnregisterAudit()
+
+	var secret corev1.Secret
+	err := k.Resource(&secret).Namespace("default").Name("token").Patch(&secret, types.MergePatchType, `{"data":{"password":"czNjcjN0"},"metadata":{"labels":{"app":"web"}}}`).Error
+	if err != nil {
+		t.Fatalf("patch error: %v", err)
+	}
+
+	select {
+	case event := <-events:
+		if event.Operation != "patch" || strings.Contains(event.Patch, "czNjcjN0") {
+			t.Fatalf("secret values should not be audited, got %+v", event)
+		}
+		if !strings.Contains(event.Patch, `"password":"sha256:`) || !strings.Contains(event.Patch, `"app":"web"`) {
+			t.Fatalf("secret values should be hashed and the rest kept, got %s", event.Patch)
+		}
+	default:
+		t.Fatalf("patch should be audited")
+	}
+}
+
+func TestWriterAuditSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := callbacks.NewWriterAuditSink(&buf)
+	for _, name := range []string{"a", "b"} {
+		if err := sink.Write(&callbacks.AuditEvent{Cluster: "c", Operation: "exec", Name: name, Command: "ls", Outcome: "success"}); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 JSON lines, got %q", buf.String())
+	}
+	var event callbacks.AuditEvent
+	if err := json.Unmarshal([]byte(lines[1]), &event); err != nil || event.Name != "b" || event.Command != "ls" {
+		t.Fatalf("unexpected line %s: %v", lines[1], err)
+	}
+}
+
+func TestAuditDrainEvictions(t *testing.T) {
+	server := newFakeAPIServer(t)
+	server.handle("/api/v1", func(w http.ResponseWriter, r *http.Request) {
+		resources := append(fakeResources(), metav1.APIResource{Name: "nodes", Kind: "Node", Verbs: []string{"get", "list", "patch"}})
+		writeJSON(w, http.StatusOK, metav1.APIResourceList{TypeMeta: metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"}, GroupVersion: "v1", APIResources: resources})
+	})
+	server.handle("/api/v1/nodes/worker", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, corev1.Node{TypeMeta: metav1.TypeMeta{Kind: "Node", APIVersion: "v1"}, ObjectMeta: metav1.ObjectMeta{Name: "worker"}})
+	})
+	server.add("pods", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-0"}, Spec: corev1.PodSpec{NodeName: "worker"}})
+	server.handle("/api/v1/namespaces/default/pods/web-0/eviction", func(w http.ResponseWriter, r *http.Request) {
+		server.remove("pods", "web-0")
+		writeJSON(w, http.StatusCreated, metav1.Status{TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}, Status: metav1.StatusSuccess})
+	})
+	k := server.register(t, "audit-drain")
+
+	events := make(chan *callbacks.AuditEvent, 10)
+	if err := callbacks.RegisterAudit(callbacks.NewChannelAuditSink(events)); err != nil {
+		t.Fatalf("register audit error: %v", err)
+	}
+	defer callbacks.UnregisterAudit()
+	m := callbacks.NewMetrics()
+	if err := m.Register(); err != nil {
+		t.Fatalf("register metrics error: %v", err)
+	}
+	defer m.Unregister()
+
+	if err := k.Resource(&corev1.Node{}).Name("worker").Ctl().Node().Drain(); err != nil {
+		t.Fatalf("drain error: %v", err)
+	}
+
+	// Evictions run through the delete callbacks, the cordon is audited as a patch
+	var evicted *callbacks.AuditEvent
+	for len(events) > 0 {
+		if event := <-events; event.Operation == "delete" {
+			evicted = event
+		}
+	}
+	if evicted == nil || !evicted.Evict || evicted.Name != "web-0" || evicted.Namespace != "default" || evicted.Outcome != "success" {
+		t.Fatalf("eviction should be audited, got %+v", evicted)
+	}
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if want := `kom_requests_total{cluster="audit-drain",operation="delete"} 1`; !strings.Contains(rec.Body.String(), want) {
+		t.Fatalf("metrics should contain %s, got:\n%s", want, rec.Body.String())
+	}
+}
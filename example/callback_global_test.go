@@ -0,0 +1,62 @@
+package example
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestGlobalCallback(t *testing.T) {
+	unreachable := &rest.Config{Host: "https://127.0.0.1:1", Timeout: time.Second}
+	if _, err := kom.Clusters().RegisterByConfigWithID(unreachable, "global-before", kom.WithLazyInit()); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	defer kom.Clusters().RemoveClusterById("global-before")
+
+	var mu sync.Mutex
+	var order []string
+	seen := map[string]bool{}
+	record := func(name string) func(*kom.Kubectl) error {
+		return func(k *kom.Kubectl) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			seen[k.ID] = true
+			return nil
+		}
+	}
+	if err := kom.GlobalCallback().Delete().Phase(kom.PhaseFinally).Register("test:global:second", record("second")); err != nil {
+		t.Fatalf("register global callback error: %v", err)
+	}
+	if err := kom.GlobalCallback().Delete().Phase(kom.PhaseFinally).Before("test:global:second").Register("test:global:first", record("first")); err != nil {
+		t.Fatalf("register global callback error: %v", err)
+	}
+	defer kom.GlobalCallback().Delete().Remove("test:global:first")
+	defer kom.GlobalCallback().Delete().Remove("test:global:second")
+
+	// Clusters registered later get the callbacks too
+	if _, err := kom.Clusters().RegisterByConfigWithID(unreachable, "global-after", kom.WithLazyInit()); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	defer kom.Clusters().RemoveClusterById("global-after")
+
+	for _, id := range []string{"global-before", "global-after"} {
+		mu.Lock()
+		order = nil
+		mu.Unlock()
+		var pod corev1.Pod
+		_ = kom.Cluster(id).Resource(&pod).Namespace("default").Name("missing").Delete()
+		mu.Lock()
+		if !seen[id] {
+			t.Fatalf("global callback not applied to cluster %s", id)
+		}
+		if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+			t.Fatalf("unexpected callback order on %s: %v", id, order)
+		}
+		mu.Unlock()
+	}
+}
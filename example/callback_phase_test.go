@@ -0,0 +1,73 @@
+package example
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestCallbackPhasesOnError(t *testing.T) {
+	// An unreachable cluster makes kom:delete fail
+	_, err := kom.Clusters().RegisterByConfigWithID(&rest.Config{Host: "https://127.0.0.1:1", Timeout: time.Second}, "phase-test", kom.WithLazyInit())
+	if err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	defer kom.Clusters().RemoveClusterById("phase-test")
+	k := kom.Cluster("phase-test")
+
+	var phases []kom.CallbackPhase
+	var seenErr error
+	var duration time.Duration
+	record := func(phase kom.CallbackPhase) func(*kom.Kubectl) error {
+		return func(k *kom.Kubectl) error {
+			phases = append(phases, phase)
+			if phase == kom.PhaseAfterError {
+				seenErr = k.Error
+				duration = k.Statement.Duration
+			}
+			return nil
+		}
+	}
+	for _, phase := range []kom.CallbackPhase{kom.PhaseFinally, kom.PhaseAfterError, kom.PhaseAfterSuccess, kom.PhaseBefore} {
+		if err := k.Callback().Delete().Phase(phase).Register("test:"+string(phase), record(phase)); err != nil {
+			t.Fatalf("register %s callback error: %v", phase, err)
+		}
+	}
+
+	var pod corev1.Pod
+	err = k.Resource(&pod).Namespace("default").Name("missing").Delete().Error
+	if err == nil {
+		t.Fatalf("delete on an unreachable cluster should fail")
+	}
+	want := []kom.CallbackPhase{kom.PhaseBefore, kom.PhaseAfterError, kom.PhaseFinally}
+	if len(phases) != len(want) {
+		t.Fatalf("expected phases %v, got %v", want, phases)
+	}
+	for i := range want {
+		if phases[i] != want[i] {
+			t.Fatalf("expected phases %v, got %v", want, phases)
+		}
+	}
+	if seenErr == nil || seenErr.Error() != err.Error() {
+		t.Fatalf("after-error callback should see the operation error, got %v", seenErr)
+	}
+	if duration <= 0 {
+		t.Fatalf("after-error callback should see the duration")
+	}
+}
+
+func TestCallbackUnknownPhase(t *testing.T) {
+	_, err := kom.Clusters().RegisterByConfigWithID(&rest.Config{Host: "https://127.0.0.1:1"}, "phase-unknown", kom.WithLazyInit())
+	if err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	defer kom.Clusters().RemoveClusterById("phase-unknown")
+
+	err = kom.Cluster("phase-unknown").Callback().Get().Phase("later").Register("test:later", func(*kom.Kubectl) error { return nil })
+	if err == nil {
+		t.Fatalf("unknown phase should be rejected")
+	}
+}
@@ -0,0 +1,107 @@
+package example
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWatchClusterAPIConfigs(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	err := kom.Clusters().WatchClusterAPIConfigs(ctx, kom.WithLazyInit())
+	if err != nil {
+		t.Skipf("CAPI not available: %v", err)
+	}
+	for id, cluster := range kom.Clusters().AllClusters() {
+		if source := cluster.Source(); source != nil {
+			t.Logf("CAPI cluster %s from %s/%s phase %s labels %v", id, source.Namespace, source.Name, source.Phase, source.Labels)
+		}
+	}
+}
+
+func TestWatchClusterAPISecretAfterCluster(t *testing.T) {
+	server := newFakeAPIServer(t)
+	hub := server.register(t, "default")
+	if kom.Clusters().DefaultCluster().ID != "default" {
+		t.Skipf("another default cluster is registered")
+	}
+	capiCluster := map[string]interface{}{
+		"apiVersion": "cluster.x-k8s.io/v1beta1",
+		"kind":       "Cluster",
+		"metadata":   map[string]interface{}{"name": "edge", "namespace": "default", "resourceVersion": "1"},
+		"status":     map[string]interface{}{"phase": "Provisioned"},
+	}
+	server.handle("/apis/cluster.x-k8s.io/v1beta1/clusters", fakeWatchable(func() []interface{} { return []interface{}{capiCluster} }, nil))
+	server.handle("/apis/cluster.x-k8s.io/v1beta1/namespaces/default/clusters/edge", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, capiCluster)
+	})
+	secretEvents := make(chan map[string]interface{}, 1)
+	server.handle("/api/v1/secrets", fakeWatchable(func() []interface{} { return []interface{}{} }, secretEvents))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := kom.Clusters().WatchClusterAPIConfigs(ctx, kom.WithLazyInit()); err != nil {
+		t.Fatalf("watch error: %v", err)
+	}
+	id := "capi-v1beta1-default-edge"
+	t.Cleanup(func() { kom.Clusters().RemoveClusterById(id) })
+	if kom.Clusters().GetClusterById(id) != nil {
+		t.Fatalf("cluster should wait for its kubeconfig secret")
+	}
+
+	// The kubeconfig secret is created once the cluster is provisioned
+	kubeconfig := fmt.Sprintf("apiVersion: v1\nkind: Config\nclusters:\n- name: edge\n  cluster:\n    server: %s\ncontexts:\n- name: edge\n  context:\n    cluster: edge\ncurrent-context: edge\n", hub.RestConfig().Host)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "edge-kubeconfig", Labels: map[string]string{"cluster.x-k8s.io/cluster-name": "edge"}},
+		Data:       map[string][]byte{"value": []byte(kubeconfig)},
+	}
+	server.add("secrets", secret)
+	secretEvents <- map[string]interface{}{"type": "ADDED", "object": server.get("secrets", "edge-kubeconfig")}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for kom.Clusters().GetClusterById(id) == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("cluster should be registered once its kubeconfig secret is added")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if source := kom.Clusters().GetClusterById(id).Source(); source == nil || source.Phase != "Provisioned" {
+		t.Fatalf("unexpected source %v", source)
+	}
+}
+
+// fakeWatchable serves a collection that can be listed and watched, events are sent as they arrive
+func fakeWatchable(items func() []interface{}, events <-chan map[string]interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") == "" {
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "List",
+				"metadata":   map[string]interface{}{"resourceVersion": "1"},
+				"items":      items(),
+			})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event := <-events:
+				_ = json.NewEncoder(w).Encode(event)
+				w.(http.Flusher).Flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package example
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/homedir"
+)
+
+func TestRemoveClusterCloses(t *testing.T) {
+	path := os.Getenv("KUBECONFIG")
+	if path == "" {
+		path = filepath.Join(homedir.HomeDir(), ".kube", "config")
+	}
+	k, err := kom.Clusters().RegisterByPathWithID(path, "to-be-removed", kom.WithLazyInit())
+	if err != nil {
+		t.Skipf("register cluster error: %v", err)
+	}
+	cluster := kom.Clusters().GetClusterById("to-be-removed")
+
+	if err = kom.Clusters().RemoveClusterById("to-be-removed"); err != nil {
+		t.Fatalf("remove cluster error %v", err)
+	}
+	if !cluster.Closed() || cluster.Context().Err() == nil {
+		t.Fatalf("removed cluster should be closed and its context cancelled")
+	}
+
+	var list []v1.Pod
+	if err = k.Resource(&v1.Pod{}).Namespace("default").List(&list).Error; err == nil {
+		t.Fatalf("operation on a removed cluster should fail")
+	}
+	if err = kom.Clusters().RemoveClusterById("to-be-removed"); err == nil {
+		t.Fatalf("removing an unknown cluster should return an error")
+	}
+}
+
+func TestRemovedClusterClients(t *testing.T) {
+	server := newFakeAPIServer(t)
+	k := server.register(t, "removed-clients")
+	if err := kom.Clusters().RemoveClusterById("removed-clients"); err != nil {
+		t.Fatalf("remove cluster error %v", err)
+	}
+
+	// Clients of a kept Kubectl fail instead of panicking
+	if k.RestConfig() == nil || k.ClusterCache() != nil {
+		t.Fatalf("removed cluster should have an unavailable config and no cache")
+	}
+	if _, err := k.Client().CoreV1().Pods("default").List(context.Background(), metav1.ListOptions{}); err == nil {
+		t.Fatalf("client of a removed cluster should fail")
+	}
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	if _, err := k.DynamicClient().Resource(gvr).Namespace("default").List(context.Background(), metav1.ListOptions{}); err == nil {
+		t.Fatalf("dynamic client of a removed cluster should fail")
+	}
+}
@@ -0,0 +1,92 @@
+package example
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+// registerFleet registers a fake cluster per ID with the given labels, each serving a ConfigMap named after it
+func registerFleet(t *testing.T, labels map[string]string, ids ...string) {
+	for _, id := range ids {
+		server := newFakeAPIServer(t)
+		server.addConfigMap("identity", map[string]interface{}{"id": id})
+		if _, err := kom.Clusters().RegisterByConfigWithID(&rest.Config{Host: server.URL}, id, kom.WithLazyInit(), kom.WithLabels(labels)); err != nil {
+			t.Fatalf("register error: %v", err)
+		}
+		t.Cleanup(func() { kom.Clusters().RemoveClusterById(id) })
+	}
+}
+
+func TestFleetForEach(t *testing.T) {
+	registerFleet(t, map[string]string{"fleet": "foreach"}, "fleet-b", "fleet-a", "fleet-c")
+
+	result := kom.Clusters().Select("fleet=foreach").Concurrency(2).ForEachValue(func(k *kom.Kubectl) (any, error) {
+		name := "identity"
+		if k.ID == "fleet-c" {
+			name = "missing"
+		}
+		var cm corev1.ConfigMap
+		if err := k.Resource(&cm).Namespace("default").Name(name).Get(&cm).Error; err != nil {
+			return nil, err
+		}
+		return cm.Data["id"], nil
+	})
+
+	if len(result.Results) != 3 {
+		t.Fatalf("expected a result per selected cluster, got %d", len(result.Results))
+	}
+	for i, id := range []string{"fleet-a", "fleet-b", "fleet-c"} {
+		if result.Results[i].ID != id {
+			t.Fatalf("results should be ordered by cluster ID, got %s at %d", result.Results[i].ID, i)
+		}
+	}
+	values := result.Values()
+	if values["fleet-a"] != "fleet-a" || values["fleet-b"] != "fleet-b" || len(values) != 2 {
+		t.Fatalf("each cluster should be visited with its own connection, got %v", values)
+	}
+	failed := result.Failed()
+	if len(failed) != 1 || failed[0].ID != "fleet-c" || failed[0].Error == nil {
+		t.Fatalf("only fleet-c should fail, got %+v", failed)
+	}
+	if err := result.Err(); err == nil || !strings.Contains(err.Error(), "fleet-c") {
+		t.Fatalf("fleet error should name the failed cluster, got %v", err)
+	}
+}
+
+func TestFleetLabelsAreCopied(t *testing.T) {
+	registerFleet(t, map[string]string{"fleet": "labels"}, "fleet-labels")
+	kom.Clusters().GetClusterById("fleet-labels").Labels()["fleet"] = "changed"
+	if ids := kom.Clusters().Select("fleet=labels").IDs(); len(ids) != 1 {
+		t.Fatalf("changing the returned labels should not change the cluster, got %v", ids)
+	}
+}
+
+func TestFleetSelectInvalidSelector(t *testing.T) {
+	result := kom.Clusters().Select("env in (").ForEach(func(k *kom.Kubectl) error {
+		t.Fatalf("no cluster should be visited")
+		return nil
+	})
+	if result.Err() == nil {
+		t.Fatalf("invalid selector should return an error")
+	}
+}
+
+func TestFleetForEachRecoversPanic(t *testing.T) {
+	registerFleet(t, map[string]string{"fleet": "panic"}, "fleet-panic-a", "fleet-panic-b")
+
+	result := kom.Clusters().Select("fleet=panic").ForEach(func(k *kom.Kubectl) error {
+		panic("boom")
+	})
+	if len(result.Results) != 2 || len(result.Failed()) != 2 {
+		t.Fatalf("every selected cluster should report the panic, got %+v", result.Results)
+	}
+	for _, r := range result.Results {
+		if r.Error == nil || !strings.Contains(r.Error.Error(), "panic: boom") {
+			t.Fatalf("cluster %s should report the recovered panic, got %v", r.ID, r.Error)
+		}
+	}
+}
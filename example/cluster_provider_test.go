@@ -0,0 +1,158 @@
+package example
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weibaohui/kom/kom"
+	"k8s.io/client-go/rest"
+)
+
+// staticProvider serves a fixed inventory that can be changed between syncs
+type staticProvider struct {
+	name     string
+	mu       sync.Mutex
+	clusters []*kom.DiscoveredCluster
+	err      error // Returned by Discover
+	calls    int   // Discover calls so far
+}
+
+func (p *staticProvider) Name() string { return p.name }
+
+func (p *staticProvider) Discover(ctx context.Context) ([]*kom.DiscoveredCluster, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	return p.clusters, p.err
+}
+
+func (p *staticProvider) set(clusters ...*kom.DiscoveredCluster) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clusters = clusters
+}
+
+func TestSyncProvider(t *testing.T) {
+	// Unique per run, a syncer of an earlier run may still prune clusters of its provider
+	provider := &staticProvider{name: fmt.Sprintf("static-%d", time.Now().UnixNano())}
+	provider.set(&kom.DiscoveredCluster{
+		ID:     "static-member",
+		Config: &rest.Config{Host: "https://127.0.0.1:6443"},
+		Source: &kom.ClusterSource{Name: "member", Phase: "Ready", Labels: map[string]string{"env": "test"}},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := kom.Clusters().SyncProvider(ctx, provider, 100*time.Millisecond, kom.WithLazyInit()); err != nil {
+		t.Fatalf("sync provider error: %v", err)
+	}
+	cluster := kom.Clusters().GetClusterById("static-member")
+	if cluster == nil {
+		t.Fatalf("cluster static-member should be registered")
+	}
+	if source := cluster.Source(); source == nil || source.Provider != provider.Name() || source.Phase != "Ready" {
+		t.Fatalf("unexpected source %+v", source)
+	}
+	if ids := kom.Clusters().Select("env=test").IDs(); len(ids) != 1 {
+		t.Fatalf("cluster labels should come from the inventory, got %v", ids)
+	}
+
+	// Label changes reach Select without re-registering
+	provider.set(&kom.DiscoveredCluster{
+		ID:     "static-member",
+		Config: &rest.Config{Host: "https://127.0.0.1:6443"},
+		Source: &kom.ClusterSource{Name: "member", Phase: "Ready", Labels: map[string]string{"env": "prod"}},
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for len(kom.Clusters().Select("env=prod").IDs()) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("cluster labels should follow the inventory, got %v", cluster.Labels())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if kom.Clusters().GetClusterById("static-member") != cluster {
+		t.Fatalf("a label change should not re-register the cluster")
+	}
+
+	// Clusters skipped for now keep their connection and are not pruned
+	provider.set(&kom.DiscoveredCluster{
+		ID:     "static-member",
+		Source: &kom.ClusterSource{Name: "member", Phase: "Unavailable", Labels: map[string]string{"env": "prod"}},
+	})
+	deadline = time.Now().Add(5 * time.Second)
+	for cluster.Source().Phase != "Unavailable" {
+		if time.Now().After(deadline) {
+			t.Fatalf("skipped cluster should have its source refreshed, got %+v", cluster.Source())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if kom.Clusters().GetClusterById("static-member") != cluster {
+		t.Fatalf("a skipped cluster should stay registered")
+	}
+
+	// Clusters that leave the inventory are unregistered
+	provider.set()
+	deadline = time.Now().Add(5 * time.Second)
+	for kom.Clusters().GetClusterById("static-member") != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("cluster static-member should be unregistered")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestSyncProviderStopsWhenFirstSyncFails(t *testing.T) {
+	provider := &staticProvider{name: fmt.Sprintf("failing-%d", time.Now().UnixNano()), err: fmt.Errorf("inventory unavailable")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := kom.Clusters().SyncProvider(ctx, provider, 10*time.Millisecond); err == nil {
+		t.Fatalf("sync provider should return the error of the first sync")
+	}
+	time.Sleep(100 * time.Millisecond)
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	if provider.calls != 1 {
+		t.Fatalf("nothing should keep syncing after the first sync failed, got %d discoveries", provider.calls)
+	}
+}
+
+func TestOCMProvider(t *testing.T) {
+	if kom.Clusters().DefaultCluster() == nil {
+		t.Skip("no default cluster")
+	}
+	hub := kom.DefaultCluster()
+	clusters, err := kom.NewOCMProvider(hub, "kom").Discover(context.Background())
+	if err != nil {
+		t.Skipf("OCM not available: %v", err)
+	}
+	for _, c := range clusters {
+		if c.Config == nil {
+			t.Logf("OCM cluster %s skipped, phase %s", c.ID, c.Source.Phase)
+			continue
+		}
+		t.Logf("OCM cluster %s at %s phase %s", c.ID, c.Config.Host, c.Source.Phase)
+	}
+}
+
+func TestKarmadaProvider(t *testing.T) {
+	if kom.Clusters().DefaultCluster() == nil {
+		t.Skip("no default cluster")
+	}
+	hub := kom.DefaultCluster()
+	clusters, err := kom.NewKarmadaProvider(hub).Discover(context.Background())
+	if err != nil {
+		t.Skipf("Karmada not available: %v", err)
+	}
+	for _, c := range clusters {
+		if c.Config == nil {
+			t.Logf("Karmada cluster %s skipped, phase %s", c.ID, c.Source.Phase)
+			continue
+		}
+		t.Logf("Karmada cluster %s at %s phase %s", c.ID, c.Config.Host, c.Source.Phase)
+	}
+}
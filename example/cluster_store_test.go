@@ -0,0 +1,148 @@
+package example
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestFileClusterStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clusters.json")
+	store := kom.NewFileClusterStore(path, nil)
+
+	err := store.Save(&kom.ClusterRecord{
+		ID:         "prod",
+		Kubeconfig: "apiVersion: v1\nkind: Config\n",
+		Options:    kom.RegisterOptions{Lazy: true, Labels: map[string]string{"env": "prod"}},
+	})
+	if err != nil {
+		t.Fatalf("save error %v", err)
+	}
+	records, err := store.List()
+	if err != nil {
+		t.Fatalf("list error %v", err)
+	}
+	if len(records) != 1 || records[0].ID != "prod" || records[0].Options.Labels["env"] != "prod" || !records[0].Options.Lazy {
+		t.Fatalf("unexpected records %+v", records)
+	}
+
+	if err = store.Delete("prod"); err != nil {
+		t.Fatalf("delete error %v", err)
+	}
+	if records, _ = store.List(); len(records) != 0 {
+		t.Fatalf("record should be deleted, got %d", len(records))
+	}
+}
+
+func TestFileClusterStoreEncrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clusters.enc")
+	store := kom.NewFileClusterStore(path, []byte("secret passphrase"))
+
+	err := store.Save(&kom.ClusterRecord{ID: "staging", Kubeconfig: "token: abc"})
+	if err != nil {
+		t.Fatalf("save error %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "token: abc") {
+		t.Fatalf("kubeconfig must not be stored in plaintext")
+	}
+
+	records, err := kom.NewFileClusterStore(path, []byte("secret passphrase")).List()
+	if err != nil || len(records) != 1 || records[0].Kubeconfig != "token: abc" {
+		t.Fatalf("reload encrypted store error %v, records %+v", err, records)
+	}
+	if _, err = kom.NewFileClusterStore(path, []byte("wrong")).List(); err == nil {
+		t.Fatalf("wrong key should fail to decrypt")
+	}
+	if _, err = kom.NewFileClusterStore(path, nil).List(); err == nil {
+		t.Fatalf("reading an encrypted store without key should fail")
+	}
+
+	// Every store gets its own random salt, so the same passphrase gives different keys
+	other := filepath.Join(t.TempDir(), "clusters.enc")
+	if err = kom.NewFileClusterStore(other, []byte("secret passphrase")).Save(&kom.ClusterRecord{ID: "staging", Kubeconfig: "token: abc"}); err != nil {
+		t.Fatalf("save error %v", err)
+	}
+	otherData, _ := os.ReadFile(other)
+	salt := func(data []byte) string {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(string(data), "kom-enc:v2:"))
+		if err != nil || len(raw) < 16 {
+			t.Fatalf("unexpected encrypted store %q", data)
+		}
+		return string(raw[:16])
+	}
+	if salt(data) == salt(otherData) {
+		t.Fatalf("stores should not share a salt")
+	}
+}
+
+func TestClusterStorePersistAndLoad(t *testing.T) {
+	server := newFakeAPIServer(t)
+	server.addConfigMap("settings", map[string]interface{}{"a": "1"})
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: fake
+  cluster:
+    server: %s
+contexts:
+- name: fake
+  context:
+    cluster: fake
+    user: fake
+current-context: fake
+users:
+- name: fake
+  user: {}
+`, server.URL)
+
+	previous := kom.Clusters().Store()
+	store := kom.NewFileClusterStore(filepath.Join(t.TempDir(), "clusters.json"), nil)
+	kom.Clusters().SetStore(store)
+	t.Cleanup(func() { kom.Clusters().SetStore(previous) })
+	t.Cleanup(func() { kom.Clusters().RemoveClusterById("stored") })
+
+	if _, err := kom.Clusters().RegisterByStringWithID(kubeconfig, "stored", kom.WithLazyInit(), kom.WithLabels(map[string]string{"env": "prod"})); err != nil {
+		t.Fatalf("register error %v", err)
+	}
+	records, err := store.List()
+	if err != nil || len(records) != 1 || records[0].ID != "stored" || records[0].Options.Labels["env"] != "prod" {
+		t.Fatalf("registered cluster should be persisted, got %+v, error %v", records, err)
+	}
+
+	// Closing keeps the record, so the cluster comes back on the next start
+	if err = kom.Clusters().CloseClusterById("stored"); err != nil {
+		t.Fatalf("close error %v", err)
+	}
+	if records, _ = store.List(); len(records) != 1 {
+		t.Fatalf("closed cluster should stay in the store, got %d records", len(records))
+	}
+	if err = kom.Clusters().LoadFromStore(); err != nil {
+		t.Fatalf("load error %v", err)
+	}
+	cluster := kom.Clusters().GetClusterById("stored")
+	if cluster == nil || cluster.Labels()["env"] != "prod" {
+		t.Fatalf("cluster should be restored with its labels")
+	}
+	var cm corev1.ConfigMap
+	if err = kom.Cluster("stored").Resource(&cm).Namespace("default").Name("settings").Get(&cm).Error; err != nil || cm.Data["a"] != "1" {
+		t.Fatalf("restored cluster should be usable, got %v, error %v", cm.Data, err)
+	}
+
+	// Removing forgets the cluster
+	if err = kom.Clusters().RemoveClusterById("stored"); err != nil {
+		t.Fatalf("remove error %v", err)
+	}
+	if records, _ = store.List(); len(records) != 0 {
+		t.Fatalf("removed cluster should be deleted from the store, got %d records", len(records))
+	}
+	if err = kom.Clusters().LoadFromStore(); err != nil || kom.Clusters().GetClusterById("stored") != nil {
+		t.Fatalf("removed cluster should not be restored, error %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package example
+
+import (
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDiffObjects(t *testing.T) {
+	left := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":            "web",
+			"namespace":       "app",
+			"uid":             "1111",
+			"resourceVersion": "10",
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "web", "image": "web:1.0"},
+					},
+				},
+			},
+		},
+		"status": map[string]interface{}{"readyReplicas": int64(2)},
+	}}
+	right := left.DeepCopy()
+	right.SetUID("2222")
+	right.SetResourceVersion("99")
+	_ = unstructured.SetNestedField(right.Object, int64(0), "status", "readyReplicas")
+	_ = unstructured.SetNestedField(right.Object, int64(5), "spec", "replicas")
+	_ = unstructured.SetNestedSlice(right.Object, []interface{}{
+		map[string]interface{}{"name": "web", "image": "web:2.0"},
+	}, "spec", "template", "spec", "containers")
+
+	diffs := kom.DiffObjects(left, right)
+	if len(diffs) != 2 {
+		t.Fatalf("expected 2 field diffs, got %+v", diffs)
+	}
+	if diffs[0].Path != "spec.replicas" || diffs[1].Path != "spec.template.spec.containers[0].image" {
+		t.Fatalf("unexpected diff paths %+v", diffs)
+	}
+}
+
+func TestDiffObjectsHidesSecretValues(t *testing.T) {
+	left := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "db"},
+		"data":       map[string]interface{}{"password": "c2VjcmV0"},
+	}}
+	right := left.DeepCopy()
+	_ = unstructured.SetNestedField(right.Object, "b3RoZXI=", "data", "password")
+
+	diffs := kom.DiffObjects(left, right)
+	if len(diffs) != 1 || diffs[0].Left == "c2VjcmV0" || diffs[0].Right == "b3RoZXI=" {
+		t.Fatalf("secret values must be compared by hash, got %+v", diffs)
+	}
+}
+
+func TestDiffClusters(t *testing.T) {
+	if kom.Clusters().DefaultCluster() == nil {
+		t.Skip("no default cluster")
+	}
+	result, err := kom.Diff(kom.DefaultCluster(), kom.DefaultCluster()).
+		Namespace("kube-system").
+		Kinds("ConfigMap", "Deployment").
+		Do()
+	if err != nil {
+		t.Fatalf("diff error %v", err)
+	}
+	if changed := result.Changed(); len(changed) > 0 {
+		t.Fatalf("a cluster compared with itself should be identical, got %d changes", len(changed))
+	}
+}
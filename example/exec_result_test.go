@@ -0,0 +1,101 @@
+package example
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weibaohui/kom/kom"
+)
+
+func TestExecResult(t *testing.T) {
+	if kom.Clusters().DefaultCluster() == nil {
+		t.Skip("no default cluster")
+	}
+	p := func() *kom.Kubectl {
+		return kom.DefaultCluster().Namespace("default").Name("random")
+	}
+
+	result, err := p().Ctl().Pod().ContainerName("random").
+		Command("sh", "-c", "echo out; echo err >&2; exit 2").
+		Run()
+	if err != nil {
+		t.Fatalf("exec error: %v", err)
+	}
+	if result.ExitCode != 2 || strings.TrimSpace(string(result.Stdout)) != "out" || strings.TrimSpace(string(result.Stderr)) != "err" {
+		t.Fatalf("unexpected result: code=%d stdout=%q stderr=%q", result.ExitCode, result.Stdout, result.Stderr)
+	}
+
+	_, err = p().Ctl().Pod().ContainerName("random").
+		Command("sleep", "10").
+		Timeout(time.Second).
+		Run()
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+
+	result, err = p().Ctl().Pod().ContainerName("random").
+		Command("seq", "1", "10000").
+		MaxOutput(10).
+		Run()
+	if err != nil {
+		t.Fatalf("exec error: %v", err)
+	}
+	if len(result.Stdout) != 10 || !result.Truncated {
+		t.Fatalf("expected 10 bytes of truncated output, got %d truncated=%v", len(result.Stdout), result.Truncated)
+	}
+}
+
+func TestExecResultExitCode(t *testing.T) {
+	server := newFakeAPIServer(t)
+	server.exec("app", func(command []string) (string, string, int) {
+		if strings.Join(command, " ") == "sh -c exit 2" {
+			return "out\n", "err\n", 2
+		}
+		return "ok\n", "", 0
+	})
+	k := server.register(t, "exec-result")
+
+	result, err := k.Namespace("default").Name("app").Ctl().Pod().ContainerName("app").Command("sh", "-c", "exit 2").Run()
+	if err != nil {
+		t.Fatalf("a non-zero exit should not be an error: %v", err)
+	}
+	if result.ExitCode != 2 || result.Success() || string(result.Stdout) != "out\n" || string(result.Stderr) != "err\n" {
+		t.Fatalf("unexpected result: code=%d stdout=%q stderr=%q", result.ExitCode, result.Stdout, result.Stderr)
+	}
+	result, err = k.Namespace("default").Name("app").Ctl().Pod().ContainerName("app").Command("true").Run()
+	if err != nil || result.ExitCode != 0 || !result.Success() {
+		t.Fatalf("unexpected result %+v, error %v", result, err)
+	}
+
+	// Without an ExecResult the exit code is still an error
+	var out []byte
+	err = k.Namespace("default").Name("app").Ctl().Pod().ContainerName("app").Command("sh", "-c", "exit 2").Execute(&out).Error
+	if err == nil || !strings.Contains(err.Error(), "exit code 2") {
+		t.Fatalf("expected an exit code error, got %v", err)
+	}
+}
+
+func TestExecResultTruncated(t *testing.T) {
+	server := newFakeAPIServer(t)
+	server.exec("app", func(command []string) (string, string, int) {
+		return strings.Repeat("o", 100), strings.Repeat("e", 5), 0
+	})
+	k := server.register(t, "exec-truncated")
+
+	result, err := k.Namespace("default").Name("app").Ctl().Pod().ContainerName("app").Command("seq", "1", "100").MaxOutput(10).Run()
+	if err != nil {
+		t.Fatalf("exec error: %v", err)
+	}
+	if string(result.Stdout) != strings.Repeat("o", 10) || string(result.Stderr) != strings.Repeat("e", 5) || !result.Truncated {
+		t.Fatalf("expected the first 10 bytes of stdout, got stdout=%q stderr=%q truncated=%v", result.Stdout, result.Stderr, result.Truncated)
+	}
+
+	result, err = k.Namespace("default").Name("app").Ctl().Pod().ContainerName("app").Command("seq", "1", "100").Run()
+	if err != nil {
+		t.Fatalf("exec error: %v", err)
+	}
+	if len(result.Stdout) != 100 || result.Truncated {
+		t.Fatalf("output should be kept without a limit, got %d bytes truncated=%v", len(result.Stdout), result.Truncated)
+	}
+}
@@ -0,0 +1,385 @@
+package example
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/remotecommand"
+	"k8s.io/client-go/rest"
+)
+
+// fakeAPIServer serves discovery and core objects in the default namespace, enough to run kom offline
+type fakeAPIServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	objects  map[string]map[string]map[string]interface{} // Objects by resource and name
+	version  int
+	failures []int                       // Status codes returned by the next mutating requests
+	requests map[string]int              // Count per method
+	handlers map[string]http.HandlerFunc // Custom handlers by path, e.g. for pod logs
+}
+
+func newFakeAPIServer(t *testing.T) *fakeAPIServer {
+	s := &fakeAPIServer{objects: map[string]map[string]map[string]interface{}{}, requests: map[string]int{}, handlers: map[string]http.HandlerFunc{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// register registers the fake server as a lazily initialized cluster
+func (s *fakeAPIServer) register(t *testing.T, id string) *kom.Kubectl {
+	k, err := kom.Clusters().RegisterByConfigWithID(&rest.Config{Host: s.URL}, id, kom.WithLazyInit())
+	if err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	t.Cleanup(func() { kom.Clusters().RemoveClusterById(id) })
+	return k
+}
+
+func (s *fakeAPIServer) addConfigMap(name string, data map[string]interface{}) {
+	s.add("configmaps", map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": name},
+		"data":       data,
+	})
+}
+
+// add stores a typed object, e.g. a *corev1.Pod, or an unstructured map under resource
+func (s *fakeAPIServer) add(resource string, obj interface{}) {
+	var m map[string]interface{}
+	if u, ok := obj.(map[string]interface{}); ok {
+		m = u
+	} else {
+		data, _ := json.Marshal(obj)
+		_ = json.Unmarshal(data, &m)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version++
+	m["apiVersion"], m["kind"] = "v1", fakeKinds[resource]
+	meta := m["metadata"].(map[string]interface{})
+	meta["namespace"] = "default"
+	meta["resourceVersion"] = strconv.Itoa(s.version)
+	if s.objects[resource] == nil {
+		s.objects[resource] = map[string]map[string]interface{}{}
+	}
+	s.objects[resource][meta["name"].(string)] = m
+}
+
+// touch changes a ConfigMap behind the client's back, so its next update conflicts
+func (s *fakeAPIServer) touch(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version++
+	s.objects["configmaps"][name]["metadata"].(map[string]interface{})["resourceVersion"] = strconv.Itoa(s.version)
+}
+
+// remove deletes an object, e.g. to end a followed log stream
+func (s *fakeAPIServer) remove(resource, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects[resource], name)
+}
+
+// handle serves path with handler instead of the object store
+func (s *fakeAPIServer) handle(path string, handler http.HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[path] = handler
+}
+
+// acceptEphemeralContainers serves the ephemeralcontainers subresource of pod name, added containers run at once
+// It returns the containers received so far.
+func (s *fakeAPIServer) acceptEphemeralContainers(name string) *[]corev1.EphemeralContainer {
+	var received []corev1.EphemeralContainer
+	s.handle("/api/v1/namespaces/default/pods/"+name+"/ephemeralcontainers", func(w http.ResponseWriter, r *http.Request) {
+		var patch struct {
+			Spec struct {
+				EphemeralContainers []corev1.EphemeralContainer `json:"ephemeralContainers"`
+			} `json:"spec"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &patch)
+
+		var item corev1.Pod
+		s.mu.Lock()
+		data, _ := json.Marshal(s.objects["pods"][name])
+		s.mu.Unlock()
+		_ = json.Unmarshal(data, &item)
+		for _, ec := range patch.Spec.EphemeralContainers {
+			received = append(received, ec)
+			item.Spec.EphemeralContainers = append(item.Spec.EphemeralContainers, ec)
+			item.Status.EphemeralContainerStatuses = append(item.Status.EphemeralContainerStatuses, corev1.ContainerStatus{
+				Name:  ec.Name,
+				State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			})
+		}
+		s.add("pods", &item)
+		writeJSON(w, http.StatusOK, s.get("pods", name))
+	})
+	return &received
+}
+
+// exec serves the exec subresource of pod name over the websocket protocol, run returns the output of a command
+func (s *fakeAPIServer) exec(name string, run func(command []string) (stdout, stderr string, code int)) {
+	upgrader := websocket.Upgrader{Subprotocols: []string{remotecommand.StreamProtocolV5Name}}
+	s.handle("/api/v1/namespaces/default/pods/"+name+"/exec", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		stdout, stderr, code := run(r.URL.Query()["command"])
+
+		// Each message starts with its channel: 1 stdout, 2 stderr, 3 the final status
+		status := metav1.Status{Status: metav1.StatusSuccess}
+		if code != 0 {
+			status = metav1.Status{
+				Status:  metav1.StatusFailure,
+				Reason:  remotecommand.NonZeroExitCodeReason,
+				Details: &metav1.StatusDetails{Causes: []metav1.StatusCause{{Type: remotecommand.ExitCodeCauseType, Message: strconv.Itoa(code)}}},
+			}
+		}
+		data, _ := json.Marshal(status)
+		_ = conn.WriteMessage(websocket.BinaryMessage, append([]byte{1}, stdout...))
+		_ = conn.WriteMessage(websocket.BinaryMessage, append([]byte{2}, stderr...))
+		_ = conn.WriteMessage(websocket.BinaryMessage, append([]byte{3}, data...))
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	})
+}
+
+// get returns a stored object
+func (s *fakeAPIServer) get(resource, name string) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.objects[resource][name]
+}
+
+func (s *fakeAPIServer) failNext(codes ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, codes...)
+}
+
+func (s *fakeAPIServer) count(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[method]
+}
+
+func (s *fakeAPIServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests[r.Method]++
+	handler := s.handlers[r.URL.Path]
+	if handler != nil {
+		s.mu.Unlock()
+		handler(w, r)
+		return
+	}
+	defer s.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/api":
+		writeJSON(w, http.StatusOK, metav1.APIVersions{TypeMeta: metav1.TypeMeta{Kind: "APIVersions"}, Versions: []string{"v1"}})
+		return
+	case "/apis":
+		writeJSON(w, http.StatusOK, metav1.APIGroupList{TypeMeta: metav1.TypeMeta{Kind: "APIGroupList", APIVersion: "v1"}})
+		return
+	case "/api/v1":
+		writeJSON(w, http.StatusOK, metav1.APIResourceList{
+			TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+			GroupVersion: "v1",
+			APIResources: fakeResources(),
+		})
+		return
+	}
+
+	const prefix = "/api/v1/namespaces/default/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		writeStatus(w, http.StatusNotFound, "NotFound", "not found")
+		return
+	}
+	resource, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	if name == "" && r.Method == http.MethodGet {
+		s.list(w, r, resource)
+		return
+	}
+	if name == "" && r.Method == http.MethodPost {
+		s.create(w, r, resource)
+		return
+	}
+
+	if r.Method != http.MethodGet && len(s.failures) > 0 {
+		code := s.failures[0]
+		s.failures = s.failures[1:]
+		writeStatus(w, code, http.StatusText(code), "injected failure")
+		return
+	}
+
+	stored, ok := s.objects[resource][name]
+	if !ok {
+		writeStatus(w, http.StatusNotFound, "NotFound", fmt.Sprintf("%s %q not found", resource, name))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, stored)
+	case http.MethodPut:
+		var obj map[string]interface{}
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &obj)
+		meta, _ := obj["metadata"].(map[string]interface{})
+		current := stored["metadata"].(map[string]interface{})["resourceVersion"]
+		if meta == nil || meta["resourceVersion"] != current {
+			writeStatus(w, http.StatusConflict, "Conflict", "the object has been modified")
+			return
+		}
+		s.version++
+		meta["resourceVersion"] = strconv.Itoa(s.version)
+		s.objects[resource][name] = obj
+		writeJSON(w, http.StatusOK, obj)
+	case http.MethodPatch:
+		var patch map[string]interface{}
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &patch)
+		if data, ok := patch["data"].(map[string]interface{}); ok {
+			existing, _ := stored["data"].(map[string]interface{})
+			if existing == nil {
+				existing = map[string]interface{}{}
+			}
+			for k, v := range data {
+				existing[k] = v
+			}
+			stored["data"] = existing
+		}
+		s.version++
+		stored["metadata"].(map[string]interface{})["resourceVersion"] = strconv.Itoa(s.version)
+		writeJSON(w, http.StatusOK, stored)
+	default:
+		writeStatus(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "method not allowed")
+	}
+}
+
+// fakeKinds are the resources served, by name
+// create stores a new object, pods start running at once as there is no scheduler
+func (s *fakeAPIServer) create(w http.ResponseWriter, r *http.Request, resource string) {
+	var obj map[string]interface{}
+	body, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(body, &obj); err != nil {
+		writeStatus(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+	meta, _ := obj["metadata"].(map[string]interface{})
+	name, _ := meta["name"].(string)
+	if _, exists := s.objects[resource][name]; exists {
+		writeStatus(w, http.StatusConflict, "AlreadyExists", fmt.Sprintf("%s %q already exists", resource, name))
+		return
+	}
+	if resource == "pods" {
+		var statuses []interface{}
+		spec, _ := obj["spec"].(map[string]interface{})
+		containers, _ := spec["containers"].([]interface{})
+		for _, c := range containers {
+			statuses = append(statuses, map[string]interface{}{
+				"name":  c.(map[string]interface{})["name"],
+				"ready": true,
+				"state": map[string]interface{}{"running": map[string]interface{}{}},
+			})
+		}
+		obj["status"] = map[string]interface{}{"phase": "Running", "containerStatuses": statuses}
+	}
+	s.version++
+	obj["apiVersion"], obj["kind"] = "v1", fakeKinds[resource]
+	meta["namespace"] = "default"
+	meta["resourceVersion"] = strconv.Itoa(s.version)
+	if s.objects[resource] == nil {
+		s.objects[resource] = map[string]map[string]interface{}{}
+	}
+	s.objects[resource][name] = obj
+	writeJSON(w, http.StatusCreated, obj)
+}
+
+var fakeKinds = map[string]string{
+	"configmaps": "ConfigMap",
+	"pods":       "Pod",
+	"services":   "Service",
+	"endpoints":  "Endpoints",
+	"secrets":    "Secret",
+}
+
+func fakeResources() []metav1.APIResource {
+	var resources []metav1.APIResource
+	for name, kind := range fakeKinds {
+		resources = append(resources, metav1.APIResource{
+			Name:       name,
+			Namespaced: true,
+			Kind:       kind,
+			Verbs:      []string{"get", "list", "create", "update", "patch", "delete"},
+		})
+	}
+	return resources
+}
+
+// list serves a collection, filtered by the labelSelector parameter
+func (s *fakeAPIServer) list(w http.ResponseWriter, r *http.Request, resource string) {
+	selector, err := labels.Parse(r.URL.Query().Get("labelSelector"))
+	if err != nil {
+		writeStatus(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+	names := make([]string, 0, len(s.objects[resource]))
+	for name := range s.objects[resource] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := []interface{}{}
+	for _, name := range names {
+		obj := s.objects[resource][name]
+		objLabels := map[string]string{}
+		if m, ok := obj["metadata"].(map[string]interface{})["labels"].(map[string]interface{}); ok {
+			for k, v := range m {
+				objLabels[k], _ = v.(string)
+			}
+		}
+		if selector.Matches(labels.Set(objLabels)) {
+			items = append(items, obj)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"metadata":   map[string]interface{}{"resourceVersion": strconv.Itoa(s.version)},
+		"items":      items,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeStatus(w http.ResponseWriter, code int, reason, message string) {
+	writeJSON(w, code, metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   metav1.StatusFailure,
+		Code:     int32(code),
+		Reason:   metav1.StatusReason(reason),
+		Message:  message,
+	})
+}
@@ -0,0 +1,78 @@
+package example
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestImpersonateList(t *testing.T) {
+	if kom.Clusters().DefaultCluster() == nil {
+		t.Skip("no default cluster")
+	}
+	var list []v1.Pod
+	err := kom.DefaultCluster().AsUser("system:serviceaccount:default:default").
+		Resource(&v1.Pod{}).Namespace("kube-system").
+		List(&list).Error
+	if err != nil && !errors.IsForbidden(err) {
+		t.Fatalf("Impersonated list error %v", err)
+	}
+	t.Logf("Impersonated list count %d, err %v", len(list), err)
+}
+
+func TestImpersonateDoesNotLeak(t *testing.T) {
+	if kom.Clusters().DefaultCluster() == nil {
+		t.Skip("no default cluster")
+	}
+	var list []v1.Pod
+	err := kom.DefaultCluster().AsUser("nobody").
+		Resource(&v1.Pod{}).Namespace("kube-system").
+		List(&list).Error
+	if err == nil {
+		t.Fatalf("expected forbidden for impersonated user nobody")
+	}
+
+	// The next chain must run with the cluster's own identity again
+	err = kom.DefaultCluster().Resource(&v1.Pod{}).Namespace("kube-system").
+		List(&list).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+}
+
+func TestImpersonateManyIdentities(t *testing.T) {
+	server := newFakeAPIServer(t)
+	var mu sync.Mutex
+	var users []string
+	server.handle("/api/v1/namespaces/default/configmaps", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		users = append(users, r.Header.Get("Impersonate-User"))
+		mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]interface{}{"apiVersion": "v1", "kind": "List", "metadata": map[string]interface{}{}, "items": []interface{}{}})
+	})
+	k := server.register(t, "impersonate-many")
+
+	// More identities than are kept, the first ones are dropped and rebuilt on their next use
+	var list []v1.ConfigMap
+	names := []string{}
+	for i := 0; i < 300; i++ {
+		names = append(names, fmt.Sprintf("user-%d", i))
+	}
+	names = append(names, "user-0", "user-299")
+	for _, name := range names {
+		if err := k.AsUser(name).Resource(&v1.ConfigMap{}).Namespace("default").List(&list).Error; err != nil {
+			t.Fatalf("list as %s error: %v", name, err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(users, ",") != strings.Join(names, ",") {
+		t.Fatalf("every request should impersonate its own user, got %v", users)
+	}
+}
@@ -0,0 +1,53 @@
+package example
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/util/homedir"
+)
+
+func TestLazyInitCluster(t *testing.T) {
+	path := os.Getenv("KUBECONFIG")
+	if path == "" {
+		path = filepath.Join(homedir.HomeDir(), ".kube", "config")
+	}
+	k, err := kom.Clusters().RegisterByPathWithID(path, "lazy", kom.WithLazyInit())
+	if err != nil {
+		t.Skipf("register lazy cluster error: %v", err)
+	}
+	defer kom.Clusters().RemoveClusterById("lazy")
+
+	// First SQL query loads the API resources on demand
+	var list []v1.Pod
+	err = k.Sql("select * from pod where metadata.namespace='kube-system'").List(&list).Error
+	if err != nil {
+		t.Fatalf("lazy cluster sql error %v", err)
+	}
+	if k.Status().ServerVersion() == nil {
+		t.Fatalf("lazy cluster server version should be loaded on first use")
+	}
+	t.Logf("lazy cluster pods %d", len(list))
+}
+
+func TestLazyInitRetriesFailedLoads(t *testing.T) {
+	server := newFakeAPIServer(t)
+	k := server.register(t, "lazy-retry")
+
+	// The fake server has no /version yet, so the first load fails
+	if k.Status().ServerVersion() != nil {
+		t.Fatalf("server version should not load while /version fails")
+	}
+	server.handle("/version", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, version.Info{Major: "1", Minor: "30", GitVersion: "v1.30.0"})
+	})
+	v := k.Status().ServerVersion()
+	if v == nil || v.GitVersion != "v1.30.0" {
+		t.Fatalf("server version should load once /version answers, got %v", v)
+	}
+}
@@ -0,0 +1,78 @@
+package example
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weibaohui/kom/callbacks"
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestMetrics(t *testing.T) {
+	_, err := kom.Clusters().RegisterByConfigWithID(&rest.Config{Host: "https://127.0.0.1:1", Timeout: time.Second}, "metrics-test", kom.WithLazyInit())
+	if err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	defer kom.Clusters().RemoveClusterById("metrics-test")
+
+	m := callbacks.NewMetrics()
+	if err := m.Register(); err != nil {
+		t.Fatalf("register metrics error: %v", err)
+	}
+	defer m.Unregister()
+
+	var pod corev1.Pod
+	for i := 0; i < 2; i++ {
+		_ = kom.Cluster("metrics-test").Resource(&pod).Namespace("default").Name("nginx").Delete()
+	}
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`kom_requests_total{cluster="metrics-test",operation="delete"} 2`,
+		`kom_request_errors_total{cluster="metrics-test",operation="delete",reason="Unknown"} 2`,
+		`kom_request_duration_seconds_count{cluster="metrics-test",operation="delete"} 2`,
+		`kom_request_duration_seconds_bucket{cluster="metrics-test",operation="delete",le="+Inf"} 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics should contain %s, got:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsForgetRemovedClusters(t *testing.T) {
+	_, err := kom.Clusters().RegisterByConfigWithID(&rest.Config{Host: "https://127.0.0.1:1", Timeout: time.Second}, "metrics-removed", kom.WithLazyInit())
+	if err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	defer kom.Clusters().RemoveClusterById("metrics-removed")
+
+	m := callbacks.NewMetrics()
+	if err := m.Register(); err != nil {
+		t.Fatalf("register metrics error: %v", err)
+	}
+	defer m.Unregister()
+	if err := callbacks.NewMetrics().Register(); err == nil {
+		t.Fatalf("a second Metrics should not replace the registered one")
+	}
+
+	var pod corev1.Pod
+	_ = kom.Cluster("metrics-removed").Resource(&pod).Namespace("default").Name("nginx").Delete()
+	var buf strings.Builder
+	if _, err := m.WriteTo(&buf); err != nil || !strings.Contains(buf.String(), `cluster="metrics-removed"`) {
+		t.Fatalf("metrics should contain the cluster, got %v:\n%s", err, buf.String())
+	}
+
+	if err := kom.Clusters().RemoveClusterById("metrics-removed"); err != nil {
+		t.Fatalf("remove error: %v", err)
+	}
+	buf.Reset()
+	if _, err := m.WriteTo(&buf); err != nil || strings.Contains(buf.String(), `cluster="metrics-removed"`) {
+		t.Fatalf("metrics of a removed cluster should be dropped, got %v:\n%s", err, buf.String())
+	}
+}
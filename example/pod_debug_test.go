@@ -0,0 +1,75 @@
+package example
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodDebugAddsEphemeralContainer(t *testing.T) {
+	server := newFakeAPIServer(t)
+	k := server.register(t, "pod-debug")
+	server.add("pods", &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-0"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "distroless"}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	received := server.acceptEphemeralContainers("app-0")
+
+	name, err := k.Namespace("default").Name("app-0").Ctl().Pod().Debug("busybox", "app", "sh")
+	if err != nil {
+		t.Fatalf("debug error: %v", err)
+	}
+	if !strings.HasPrefix(name, "debugger-") || len(*received) != 1 {
+		t.Fatalf("unexpected debug container %q, received %+v", name, *received)
+	}
+	ec := (*received)[0]
+	if ec.Name != name || ec.Image != "busybox" || ec.TargetContainerName != "app" || !ec.Stdin || !ec.TTY || ec.Command[0] != "sh" {
+		t.Fatalf("unexpected ephemeral container %+v", ec)
+	}
+}
+
+func TestPodDebugCopy(t *testing.T) {
+	server := newFakeAPIServer(t)
+	k := server.register(t, "pod-debug-copy")
+	server.add("pods", &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-0", Labels: map[string]string{"app": "web"}},
+		Spec: corev1.PodSpec{
+			NodeName: "node-1",
+			Containers: []corev1.Container{
+				{Name: "app", Image: "app:1", Args: []string{"--serve"}, LivenessProbe: &corev1.Probe{}},
+				{Name: "sidecar", Image: "sidecar:1"},
+			},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	copied, err := k.Namespace("default").Name("app-0").Ctl().Pod().DebugCopy(kom.DebugCopyOptions{
+		Images:                map[string]string{"app": "app:debug"},
+		Container:             "app",
+		Command:               []string{"sleep", "infinity"},
+		ShareProcessNamespace: true,
+	})
+	if err != nil {
+		t.Fatalf("debug copy error: %v", err)
+	}
+	app := copied.Spec.Containers[0]
+	if copied.Name != "app-0-debug" || len(copied.Labels) != 0 || copied.Spec.NodeName != "" || !*copied.Spec.ShareProcessNamespace {
+		t.Fatalf("unexpected copy %+v", copied.ObjectMeta)
+	}
+	if app.Image != "app:debug" || app.Command[0] != "sleep" || app.Args != nil || app.LivenessProbe != nil {
+		t.Fatalf("unexpected app container %+v", app)
+	}
+	if copied.Spec.Containers[1].Image != "sidecar:1" {
+		t.Fatalf("sidecar image should be kept, got %s", copied.Spec.Containers[1].Image)
+	}
+
+	_, err = k.Namespace("default").Name("app-0").Ctl().Pod().DebugCopy(kom.DebugCopyOptions{Name: "other", Container: "missing"})
+	if err == nil || !strings.Contains(err.Error(), "container missing not found") {
+		t.Fatalf("expected a missing container error, got %v", err)
+	}
+}
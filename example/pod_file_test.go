@@ -1,14 +1,19 @@
 package example
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/weibaohui/kom/kom"
 	"github.com/weibaohui/kom/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestUploadFile(t *testing.T) {
@@ -223,3 +228,189 @@ func dd(filePath string, size int64) error {
 	fmt.Printf("Successfully created a file of size %d bytes: %s\n", fileSize, filePath)
 	return nil
 }
+
+// localExec replaces the exec callback of k to run commands on this machine
+// Containers named in distroless have no commands, helper containers see this machine under /proc/1/root.
+func localExec(t *testing.T, k *kom.Kubectl, distroless ...string) {
+	err := k.Callback().Exec().Replace("kom:pod:exec", func(k *kom.Kubectl) error {
+		stmt := k.Statement
+		var result kom.ExecResult
+		var stdout, stderr bytes.Buffer
+		for _, name := range distroless {
+			if stmt.ContainerName == name {
+				result.ExitCode = 127
+				fmt.Fprintf(&stderr, "exec: %q: executable file not found in $PATH", stmt.Command)
+			}
+		}
+		if result.ExitCode == 0 {
+			var args []string
+			for _, arg := range stmt.Args {
+				if strings.HasPrefix(stmt.ContainerName, "debugger-") {
+					arg = strings.Replace(arg, "/proc/1/root", "", 1)
+				}
+				args = append(args, arg)
+			}
+			cmd := exec.Command(stmt.Command, args...)
+			cmd.Stdin, cmd.Stdout, cmd.Stderr = stmt.Stdin, &stdout, &stderr
+			if stmt.Stdout != nil {
+				cmd.Stdout = stmt.Stdout
+			}
+			var exitErr *exec.ExitError
+			if err := cmd.Run(); errors.As(err, &exitErr) {
+				result.ExitCode = exitErr.ExitCode()
+			} else if err != nil {
+				return err
+			}
+		}
+		result.Stdout, result.Stderr = stdout.Bytes(), stderr.Bytes()
+		switch dest := stmt.Dest.(type) {
+		case *kom.ExecResult:
+			*dest = result
+		case *[]byte:
+			if result.ExitCode != 0 {
+				return fmt.Errorf("command terminated with exit code %d %s", result.ExitCode, result.Stderr)
+			}
+			*dest = result.Stdout
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("replace exec callback error: %v", err)
+	}
+}
+
+// newFileTestPod serves pod app-0 with containers app and distroless
+func newFileTestPod(t *testing.T, id string) (*fakeAPIServer, *kom.Kubectl) {
+	server := newFakeAPIServer(t)
+	k := server.register(t, id)
+	server.add("pods", &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-0"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}, {Name: "distroless"}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+	localExec(t, k, "distroless")
+	return server, k
+}
+
+func TestPodFileStat(t *testing.T) {
+	_, k := newFileTestPod(t, "pod-file-stat")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "with space.txt"), []byte("hello"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, ".hidden"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("with space.txt", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := k.Namespace("default").Name("app-0").Ctl().Pod().ContainerName("app").ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir error: %v", err)
+	}
+	byName := map[string]*kom.FileStat{}
+	for _, stat := range stats {
+		byName[stat.Name] = stat
+	}
+	file, hidden, link := byName["with space.txt"], byName[".hidden"], byName["link"]
+	if len(stats) != 3 || file == nil || hidden == nil || link == nil {
+		t.Fatalf("unexpected entries %v", byName)
+	}
+	if file.Type != "file" || file.Mode != 0640 || file.Size != 5 || file.Path != filepath.Join(dir, "with space.txt") || file.ModTime.IsZero() {
+		t.Fatalf("unexpected file stat %+v", file)
+	}
+	if !hidden.IsDir() || hidden.Mode.Perm() != 0750 {
+		t.Fatalf("unexpected directory stat %+v", hidden)
+	}
+	if link.Type != "link" || link.LinkTarget != "with space.txt" {
+		t.Fatalf("unexpected link stat %+v", link)
+	}
+
+	stat, err := k.Namespace("default").Name("app-0").Ctl().Pod().ContainerName("app").Stat(filepath.Join(dir, "with space.txt"))
+	if err != nil || stat.Size != 5 {
+		t.Fatalf("unexpected stat %+v, error %v", stat, err)
+	}
+
+	files, err := k.Namespace("default").Name("app-0").Ctl().Pod().ContainerName("app").ListFiles(dir)
+	if err != nil || len(files) != 2 {
+		t.Fatalf("expected the hidden directory to be skipped, got %v, error %v", files, err)
+	}
+	for _, f := range files {
+		if f.Name == "with space.txt" && f.Permissions != "-rw-r-----" {
+			t.Fatalf("unexpected permissions %s", f.Permissions)
+		}
+		if f.Name == "with space.txt" && f.ModTime != file.ModTime.UTC().Format("Jan 2 15:04") {
+			t.Fatalf("modification time should keep the ls -l format, got %s", f.ModTime)
+		}
+	}
+}
+
+func TestPodFileFailureSkipsToolCheck(t *testing.T) {
+	_, k := newFileTestPod(t, "pod-file-failure")
+	var commands []string
+	err := k.Callback().Exec().Before("kom:pod:exec").Register("record", func(k *kom.Kubectl) error {
+		commands = append(commands, strings.Join(append([]string{k.Statement.Command}, k.Statement.Args...), " "))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("register callback error: %v", err)
+	}
+
+	// The tools ran and reported the missing file, there is nothing to look for
+	_, err = k.Namespace("default").Name("app-0").Ctl().Pod().ContainerName("app").Stat(filepath.Join(t.TempDir(), "missing"))
+	if err == nil {
+		t.Fatalf("stat of a missing file should fail")
+	}
+	for _, cmd := range commands {
+		if strings.HasSuffix(cmd, "--help") {
+			t.Fatalf("a failed command should not look for missing tools, ran %q", commands)
+		}
+	}
+}
+
+func TestPodFileHelper(t *testing.T) {
+	server, k := newFileTestPod(t, "pod-file-helper")
+	received := server.acceptEphemeralContainers("app-0")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.conf"), []byte("a=1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := k.Namespace("default").Name("app-0").Ctl().Pod().ContainerName("distroless").ReadDir(dir)
+	var missing *kom.MissingToolError
+	if !errors.As(err, &missing) || missing.Container != "distroless" || len(missing.Tools) != 3 {
+		t.Fatalf("expected a missing tool error, got %v", err)
+	}
+
+	stats, err := k.Namespace("default").Name("app-0").Ctl().Pod().ContainerName("distroless").FileHelper("busybox").ReadDir(dir)
+	if err != nil || len(stats) != 1 || stats[0].Path != filepath.Join(dir, "app.conf") {
+		t.Fatalf("unexpected helper listing %v, error %v", stats, err)
+	}
+	data, err := k.Namespace("default").Name("app-0").Ctl().Pod().ContainerName("distroless").FileHelper("busybox").
+		DownloadFile(filepath.Join(dir, "app.conf"))
+	if err != nil || string(data) != "a=1" {
+		t.Fatalf("unexpected download through helper %q, error %v", data, err)
+	}
+	err = k.Namespace("default").Name("app-0").Ctl().Pod().ContainerName("distroless").FileHelper("busybox").
+		SaveFile(filepath.Join(dir, "new file"), "saved")
+	if err != nil {
+		t.Fatalf("save through helper error: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "new file")); string(data) != "saved" {
+		t.Fatalf("unexpected saved content %q", data)
+	}
+	_, err = k.Namespace("default").Name("app-0").Ctl().Pod().ContainerName("distroless").FileHelper("busybox").
+		DeleteFile(filepath.Join(dir, "app.conf"))
+	if _, statErr := os.Stat(filepath.Join(dir, "app.conf")); err != nil || !os.IsNotExist(statErr) {
+		t.Fatalf("expected app.conf to be deleted, error %v", err)
+	}
+
+	if len(*received) != 1 {
+		t.Fatalf("expected one reused helper container, got %d", len(*received))
+	}
+	ec := (*received)[0]
+	if ec.Image != "busybox" || ec.TargetContainerName != "distroless" {
+		t.Fatalf("unexpected helper container %+v", ec)
+	}
+}
@@ -2,12 +2,16 @@ package example
 
 import (
 	"bufio"
+	"fmt"
 	"io"
+	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/weibaohui/kom/kom"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestPodLogs(t *testing.T) {
@@ -38,3 +42,63 @@ func TestPodLogs(t *testing.T) {
 	}
 
 }
+
+func TestStreamLogsReconnects(t *testing.T) {
+	server := newFakeAPIServer(t)
+	k := server.register(t, "stream-logs")
+	server.add("pods", &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-0"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	var queries []string
+	server.handle("/api/v1/namespaces/default/pods/web-0/log", func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		switch len(queries) {
+		case 1:
+			// The container restarts after two lines
+			fmt.Fprint(w, "2024-01-01T00:00:01.1Z a\n2024-01-01T00:00:01.2Z b\n")
+		default:
+			// The overlapping line b is dropped, then the pod is deleted
+			fmt.Fprint(w, "2024-01-01T00:00:01.2Z b\n2024-01-01T00:00:02Z c\n")
+			server.remove("pods", "web-0")
+		}
+	})
+
+	var lines []string
+	err := k.Namespace("default").Name("web-0").Ctl().Pod().ContainerName("web").
+		StreamLogs(kom.NewLogOptions().Follow().Tail(10), func(line []byte) error {
+			lines = append(lines, string(line))
+			return nil
+		}).Error
+	if err != nil {
+		t.Fatalf("stream logs error: %v", err)
+	}
+	if got := strings.Join(lines, ","); got != "a,b,c" {
+		t.Fatalf("expected a,b,c, got %s", got)
+	}
+	if len(queries) != 2 || !strings.Contains(queries[0], "tailLines=10") || !strings.Contains(queries[1], "sinceTime=2024-01-01T00%3A00%3A01Z") {
+		t.Fatalf("unexpected log requests %v", queries)
+	}
+}
+
+func TestStreamLogsStop(t *testing.T) {
+	server := newFakeAPIServer(t)
+	k := server.register(t, "stream-logs-stop")
+	server.handle("/api/v1/namespaces/default/pods/web-0/log", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "a\nb\nc\n")
+	})
+
+	var lines []string
+	err := k.Namespace("default").Name("web-0").Ctl().Pod().
+		StreamLogs(kom.NewLogOptions().Since(time.Minute).Timestamps(), func(line []byte) error {
+			lines = append(lines, string(line))
+			if len(lines) == 2 {
+				return kom.ErrStopLogs
+			}
+			return nil
+		}).Error
+	if err != nil || len(lines) != 2 {
+		t.Fatalf("expected to stop after 2 lines, got %v %v", lines, err)
+	}
+}
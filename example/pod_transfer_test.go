@@ -0,0 +1,223 @@
+package example
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+)
+
+func TestPodUploadDownloadStream(t *testing.T) {
+	_, k := newFileTestPod(t, "pod-transfer-file")
+	dest := filepath.Join(t.TempDir(), "heap dump.hprof")
+	content := bytes.Repeat([]byte("0123456789"), 100000)
+
+	var progress []kom.TransferProgress
+	err := k.Namespace("default").Name("app-0").Ctl().Pod().ContainerName("app").
+		Upload(dest, bytes.NewReader(content), &kom.TransferOptions{
+			Mode:     0600,
+			Progress: func(p kom.TransferProgress) { progress = append(progress, p) },
+		})
+	if err != nil {
+		t.Fatalf("upload error: %v", err)
+	}
+	info, err := os.Stat(dest)
+	if err != nil || info.Size() != int64(len(content)) || info.Mode().Perm() != 0600 {
+		t.Fatalf("unexpected uploaded file %v, error %v", info, err)
+	}
+	last := progress[len(progress)-1]
+	if last.Bytes != int64(len(content)) || last.Files != 1 {
+		t.Fatalf("unexpected final progress %+v", last)
+	}
+
+	var out bytes.Buffer
+	progress = nil
+	err = k.Namespace("default").Name("app-0").Ctl().Pod().ContainerName("app").
+		Download(dest, &out, &kom.TransferOptions{Progress: func(p kom.TransferProgress) { progress = append(progress, p) }})
+	if err != nil {
+		t.Fatalf("download error: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), content) {
+		t.Fatalf("downloaded %d bytes, expected %d", out.Len(), len(content))
+	}
+	last = progress[len(progress)-1]
+	if last.Total != int64(len(content)) || last.Bytes != last.Total {
+		t.Fatalf("unexpected final progress %+v", last)
+	}
+
+	// Paths through a symbolic link are verified against the file it points to
+	link := filepath.Join(filepath.Dir(dest), "latest.hprof")
+	if err = os.Symlink(filepath.Base(dest), link); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err = k.Namespace("default").Name("app-0").Ctl().Pod().ContainerName("app").Download(link, &out, nil); err != nil || !bytes.Equal(out.Bytes(), content) {
+		t.Fatalf("download through link error: %v", err)
+	}
+	if err = k.Namespace("default").Name("app-0").Ctl().Pod().ContainerName("app").Upload(link, strings.NewReader("replaced"), nil); err != nil {
+		t.Fatalf("upload through link error: %v", err)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "replaced" {
+		t.Fatalf("upload through link should replace the target, got %d bytes", len(data))
+	}
+
+	err = k.Namespace("default").Name("app-0").Ctl().Pod().ContainerName("app").Download(filepath.Dir(dest), &out, nil)
+	if err == nil || !strings.Contains(err.Error(), "DownloadDir") {
+		t.Fatalf("expected a directory error, got %v", err)
+	}
+}
+
+func TestPodUploadDownloadDir(t *testing.T) {
+	server, k := newFileTestPod(t, "pod-transfer-dir")
+	server.acceptEphemeralContainers("app-0")
+	local := t.TempDir()
+	writeFile(t, filepath.Join(local, "bin", "run.sh"), "#!/bin/sh\necho run\n", 0755)
+	writeFile(t, filepath.Join(local, "conf", "app settings.yaml"), "a: 1\n", 0640)
+	if err := os.Symlink("conf/app settings.yaml", filepath.Join(local, "current")); err != nil {
+		t.Fatal(err)
+	}
+
+	remote := filepath.Join(t.TempDir(), "app")
+	var files int
+	err := k.Namespace("default").Name("app-0").Ctl().Pod().ContainerName("app").
+		UploadDir(local, remote, &kom.TransferOptions{Progress: func(p kom.TransferProgress) { files = p.Files }})
+	if err != nil {
+		t.Fatalf("upload dir error: %v", err)
+	}
+	if files != 2 {
+		t.Fatalf("expected progress of 2 files, got %d", files)
+	}
+	assertTree(t, remote)
+	// Hard links arrive as links to the first name
+	if err = os.Link(filepath.Join(remote, "bin", "run.sh"), filepath.Join(remote, "bin", "start.sh")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The distroless container has no tar, the helper container is used
+	downloaded := filepath.Join(t.TempDir(), "copy")
+	err = k.Namespace("default").Name("app-0").Ctl().Pod().ContainerName("distroless").FileHelper("busybox").
+		DownloadDir(remote, downloaded, nil)
+	if err != nil {
+		t.Fatalf("download dir error: %v", err)
+	}
+	assertTree(t, downloaded)
+	if start, err := os.ReadFile(filepath.Join(downloaded, "bin", "start.sh")); err != nil || string(start) != "#!/bin/sh\necho run\n" {
+		t.Fatalf("unexpected hard linked file %q, error %v", start, err)
+	}
+}
+
+func TestPodDownloadDirKeepsLinkChainsInside(t *testing.T) {
+	_, k := newFileTestPod(t, "pod-transfer-escape")
+	// The archive a compromised container could send: l1 -> ., l1/l2 -> .., l2/evil
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	for _, hdr := range []*tar.Header{
+		{Name: "l1", Typeflag: tar.TypeSymlink, Linkname: "."},
+		{Name: "l1/l2", Typeflag: tar.TypeSymlink, Linkname: ".."},
+		{Name: "l2/evil", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := tw.Write([]byte("evil")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	err := k.Callback().Exec().Replace("kom:pod:exec", func(k *kom.Kubectl) error {
+		if k.Statement.Command == "tar" {
+			_, err := k.Statement.Stdout.Write(archive.Bytes())
+			return err
+		}
+		return fmt.Errorf("unexpected command %s", k.Statement.Command)
+	})
+	if err != nil {
+		t.Fatalf("replace exec callback error: %v", err)
+	}
+
+	parent := t.TempDir()
+	local := filepath.Join(parent, "out")
+	err = k.Namespace("default").Name("app-0").Ctl().Pod().ContainerName("app").DownloadDir("/data", local, &kom.TransferOptions{NoVerify: true})
+	if err != nil {
+		t.Fatalf("download dir error: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(parent, "evil")); err == nil {
+		t.Fatalf("a chain of links must not write outside of the local directory")
+	}
+	if info, err := os.Lstat(filepath.Join(local, "l2")); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Fatalf("the link to .. should be skipped, got %v, error %v", info, err)
+	}
+}
+
+func TestPodUploadVerifiesChecksum(t *testing.T) {
+	_, k := newFileTestPod(t, "pod-transfer-checksum")
+	err := k.Callback().Exec().Before("kom:pod:exec").Register("test:corrupt", func(k *kom.Kubectl) error {
+		if k.Statement.Command == "dd" && k.Statement.Stdin != nil {
+			k.Statement.Stdin = &corruptReader{r: k.Statement.Stdin}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "data")
+	err = k.Namespace("default").Name("app-0").Ctl().Pod().ContainerName("app").Upload(dest, strings.NewReader("payload"), nil)
+	if err == nil || !strings.Contains(err.Error(), "checksum of "+dest+" does not match") {
+		t.Fatalf("expected a checksum error, got %v", err)
+	}
+}
+
+// corruptReader flips the first byte of every read, after the uploader has hashed it
+type corruptReader struct {
+	r io.Reader
+}
+
+func (c *corruptReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	if n > 0 {
+		b[0] ^= 0xff
+	}
+	return n, err
+}
+
+func writeFile(t *testing.T, file, content string, mode os.FileMode) {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(file, mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// assertTree checks a copy of the tree written in TestPodUploadDownloadDir
+func assertTree(t *testing.T, dir string) {
+	t.Helper()
+	run, err := os.Stat(filepath.Join(dir, "bin", "run.sh"))
+	if err != nil || run.Mode().Perm() != 0755 {
+		t.Fatalf("unexpected run.sh %v, error %v", run, err)
+	}
+	conf, err := os.ReadFile(filepath.Join(dir, "current"))
+	if err != nil || string(conf) != "a: 1\n" {
+		t.Fatalf("unexpected content through link %q, error %v", conf, err)
+	}
+	link, err := os.Readlink(filepath.Join(dir, "current"))
+	if err != nil || link != "conf/app settings.yaml" {
+		t.Fatalf("unexpected link %q, error %v", link, err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "conf", "app settings.yaml"))
+	if err != nil || info.Mode().Perm() != 0640 {
+		t.Fatalf("unexpected settings file %v, error %v", info, err)
+	}
+}
@@ -0,0 +1,84 @@
+package example
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodsRunSelectsContainers(t *testing.T) {
+	server := newFakeAPIServer(t)
+	k := server.register(t, "pods-exec")
+
+	newPod := func(name string, phase corev1.PodPhase, containers ...string) *corev1.Pod {
+		item := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"app": "web"}},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+		for _, c := range containers {
+			item.Spec.Containers = append(item.Spec.Containers, corev1.Container{Name: c})
+		}
+		return item
+	}
+	server.add("pods", newPod("web-0", corev1.PodRunning, "web", "sidecar"))
+	server.add("pods", newPod("web-1", corev1.PodRunning, "web", "sidecar"))
+	server.add("pods", newPod("web-2", corev1.PodPending, "web"))
+	other := newPod("db-0", corev1.PodRunning, "db")
+	other.Labels["app"] = "db"
+	server.add("pods", other)
+
+	results, err := k.Namespace("default").WithLabelSelector("app=web").Ctl().Pods().
+		AllContainers().
+		Concurrency(2).
+		Command("date").
+		Run()
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	var targets []string
+	for _, r := range results {
+		targets = append(targets, r.Pod+"/"+r.Container)
+		if r.Error == nil {
+			t.Fatalf("expected %s/%s to fail without a container runtime", r.Pod, r.Container)
+		}
+	}
+	if got := strings.Join(targets, ","); got != "web-0/web,web-0/sidecar,web-1/web,web-1/sidecar,web-2/" {
+		t.Fatalf("unexpected targets %s", got)
+	}
+	if !strings.Contains(results[4].Error.Error(), "not Running") {
+		t.Fatalf("expected the pending pod to be reported, got %v", results[4].Error)
+	}
+
+	results, err = k.Namespace("default").Where("metadata.name='web-0'").Ctl().Pods().
+		ContainerName("missing").
+		Command("date").
+		Run()
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	if len(results) != 1 || !strings.Contains(results[0].Error.Error(), "container missing not found") {
+		t.Fatalf("expected a missing container error, got %v", results)
+	}
+}
+
+func TestDeploymentPodsRun(t *testing.T) {
+	if kom.Clusters().DefaultCluster() == nil {
+		t.Skip("no default cluster")
+	}
+	results, err := kom.DefaultCluster().Namespace("default").Name("managed-pods").
+		Ctl().Deployment().Pods().
+		Command("nginx", "-v").
+		Run()
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	for _, r := range results {
+		if r.Error != nil {
+			t.Fatalf("exec in %s/%s error: %v", r.Pod, r.Container, r.Error)
+		}
+		t.Logf("%s/%s exit %d: %s", r.Pod, r.Container, r.Result.ExitCode, r.Result.Stderr)
+	}
+}
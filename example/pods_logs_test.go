@@ -0,0 +1,155 @@
+package example
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestPodsLogsMergesAndPicksUpNewPods(t *testing.T) {
+	server := newFakeAPIServer(t)
+	k := server.register(t, "pods-logs")
+
+	addPod := func(name string) {
+		server.add("pods", &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID("uid-" + name), Labels: map[string]string{"app": "web"}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}, {Name: "sidecar"}}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		})
+		server.handle("/api/v1/namespaces/default/pods/"+name+"/log", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "2024-01-01T00:00:01Z hello from %s\n", r.URL.Query().Get("container"))
+		})
+	}
+	addPod("web-0")
+	addPod("web-1")
+
+	var lines []string
+	err := k.Namespace("default").WithLabelSelector("app=web").Ctl().Pods().
+		AllContainers().
+		Logs(kom.NewLogOptions(), func(line *kom.LogLine) error {
+			lines = append(lines, line.String())
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("logs error: %v", err)
+	}
+	sort.Strings(lines)
+	expected := "[web-0/sidecar] 2024-01-01T00:00:01Z hello from sidecar," +
+		"[web-0/web] 2024-01-01T00:00:01Z hello from web," +
+		"[web-1/sidecar] 2024-01-01T00:00:01Z hello from sidecar," +
+		"[web-1/web] 2024-01-01T00:00:01Z hello from web"
+	if got := strings.Join(lines, ","); got != expected {
+		t.Fatalf("unexpected lines %s", got)
+	}
+
+	// Following picks up a pod created later, lines seen again after reconnects are dropped
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	seen := map[string]int{}
+	err = k.WithContext(ctx).Namespace("default").WithLabelSelector("app=web").Ctl().Pods().
+		Logs(kom.NewLogOptions().Follow(), func(line *kom.LogLine) error {
+			seen[line.Pod]++
+			if line.Pod == "web-1" && seen["web-2"] == 0 {
+				addPod("web-2")
+			}
+			if line.Pod == "web-2" {
+				return kom.ErrStopLogs
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("follow logs error: %v", err)
+	}
+	if seen["web-0"] != 1 || seen["web-1"] != 1 || seen["web-2"] != 1 {
+		t.Fatalf("expected one line per pod, got %v", seen)
+	}
+}
+
+func TestPodsLogsRetriesContainersThatNeverConnected(t *testing.T) {
+	server := newFakeAPIServer(t)
+	k := server.register(t, "pods-logs-retry")
+	server.add("pods", &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "slow-0", UID: "uid-slow-0", Labels: map[string]string{"app": "slow"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+	var requests atomic.Int32
+	server.handle("/api/v1/namespaces/default/pods/slow-0/log", func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			writeStatus(w, http.StatusBadRequest, "BadRequest", `container "app" is waiting to start: ContainerCreating`)
+			return
+		}
+		fmt.Fprintln(w, "2024-01-01T00:00:01Z started")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var lines []string
+	err := k.WithContext(ctx).Namespace("default").WithLabelSelector("app=slow").Ctl().Pods().
+		Logs(kom.NewLogOptions().Follow(), func(line *kom.LogLine) error {
+			lines = append(lines, string(line.Line))
+			return kom.ErrStopLogs
+		})
+	if err != nil {
+		t.Fatalf("follow logs error: %v", err)
+	}
+	if len(lines) != 1 || lines[0] != "started" || requests.Load() < 2 {
+		t.Fatalf("a container that was not started should be retried, got %q after %d requests", lines, requests.Load())
+	}
+}
+
+func TestPodsLogsReturnsStreamErrors(t *testing.T) {
+	server := newFakeAPIServer(t)
+	k := server.register(t, "pods-logs-errors")
+	for _, name := range []string{"ok-0", "denied-0"} {
+		server.add("pods", &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID("uid-" + name), Labels: map[string]string{"app": "mixed"}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		})
+	}
+	server.handle("/api/v1/namespaces/default/pods/ok-0/log", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "2024-01-01T00:00:01Z hello")
+	})
+	server.handle("/api/v1/namespaces/default/pods/denied-0/log", func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, http.StatusForbidden, "Forbidden", "logs are forbidden")
+	})
+
+	// The other pods are still read, the failed stream is returned
+	var lines []string
+	err := k.Namespace("default").WithLabelSelector("app=mixed").Ctl().Pods().
+		Logs(kom.NewLogOptions(), func(line *kom.LogLine) error {
+			lines = append(lines, line.String())
+			return nil
+		})
+	if err == nil || !strings.Contains(err.Error(), "denied-0") || !strings.Contains(err.Error(), "forbidden") {
+		t.Fatalf("expected the error of denied-0, got %v", err)
+	}
+	if len(lines) != 1 || lines[0] != "[ok-0/app] 2024-01-01T00:00:01Z hello" {
+		t.Fatalf("unexpected lines %q", lines)
+	}
+}
+
+func TestDeploymentLogs(t *testing.T) {
+	if kom.Clusters().DefaultCluster() == nil {
+		t.Skip("no default cluster")
+	}
+	err := kom.DefaultCluster().Namespace("default").Name("managed-pods").Ctl().Deployment().
+		Logs(kom.NewLogOptions().Tail(1), func(line *kom.LogLine) error {
+			t.Log(line)
+			return nil
+		})
+	if err != nil {
+		t.Logf("deployment logs error: %v", err)
+	}
+}
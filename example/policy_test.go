@@ -0,0 +1,120 @@
+package example
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weibaohui/kom/callbacks"
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+const testPolicy = `
+rules:
+- name: read-only-prod
+  clusterSelector: env=prod
+  message: production clusters are read-only
+- name: protect-kube-system
+  operations: [delete]
+  namespaces: [kube-system]
+- name: exec-dev-only
+  operations: [exec, stream-exec]
+  exceptNamespaces: [dev]
+- name: no-force-delete
+  operations: [delete]
+  forceDelete: true
+- name: no-port-forward
+  operations: [port-forward]
+`
+
+func TestPolicy(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(file, []byte(testPolicy), 0600); err != nil {
+		t.Fatalf("write policy error: %v", err)
+	}
+	policy, err := callbacks.LoadPolicyFile(file)
+	if err != nil {
+		t.Fatalf("load policy error: %v", err)
+	}
+
+	unreachable := &rest.Config{Host: "https://127.0.0.1:1", Timeout: time.Second}
+	if _, err := kom.Clusters().RegisterByConfigWithID(unreachable, "policy-prod", kom.WithLazyInit(), kom.WithLabels(map[string]string{"env": "prod"})); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	defer kom.Clusters().RemoveClusterById("policy-prod")
+	if _, err := kom.Clusters().RegisterByConfigWithID(unreachable, "policy-dev", kom.WithLazyInit()); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	defer kom.Clusters().RemoveClusterById("policy-dev")
+
+	if err := callbacks.RegisterPolicy(policy); err != nil {
+		t.Fatalf("register policy error: %v", err)
+	}
+	defer callbacks.UnregisterPolicy()
+
+	denied := func(name string, err error, rule string) {
+		t.Helper()
+		if !callbacks.IsPolicyDenied(err) || !strings.Contains(err.Error(), rule) {
+			t.Fatalf("%s should be denied by %s, got %v", name, rule, err)
+		}
+	}
+	allowed := func(name string, err error) {
+		t.Helper()
+		if callbacks.IsPolicyDenied(err) {
+			t.Fatalf("%s should not be denied, got %v", name, err)
+		}
+	}
+
+	var pod corev1.Pod
+	denied("delete on prod", kom.Cluster("policy-prod").Resource(&pod).Namespace("default").Name("nginx").Delete().Error, "read-only-prod")
+	denied("delete in kube-system", kom.Cluster("policy-dev").Resource(&pod).Namespace("kube-system").Name("coredns").Delete().Error, "protect-kube-system")
+	denied("force delete", kom.Cluster("policy-dev").Resource(&pod).Namespace("default").Name("nginx").ForceDelete().Error, "no-force-delete")
+	allowed("delete in default", kom.Cluster("policy-dev").Resource(&pod).Namespace("default").Name("nginx").Delete().Error)
+
+	var out []byte
+	err = kom.Cluster("policy-dev").Resource(&pod).Namespace("default").Name("nginx").Ctl().Pod().ContainerName("nginx").Command("ls").Execute(&out).Error
+	denied("exec in default", err, "exec-dev-only")
+	err = kom.Cluster("policy-dev").Resource(&pod).Namespace("dev").Name("nginx").Ctl().Pod().ContainerName("nginx").Command("ls").Execute(&out).Error
+	allowed("exec in dev", err)
+	_, err = kom.Cluster("policy-dev").Namespace("dev").Name("nginx").Ctl().Pod().PortForward(0, 80)
+	denied("port forward", err, "no-port-forward")
+
+	// The Applier goes through the same checks
+	result := kom.Cluster("policy-prod").Applier().Apply(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+  namespace: default
+`)
+	if len(result) != 1 || !strings.Contains(result[0], "read-only-prod") {
+		t.Fatalf("apply on prod should be denied, got %v", result)
+	}
+}
+
+func TestRegisterNilPolicy(t *testing.T) {
+	policy := &callbacks.Policy{Rules: []callbacks.PolicyRule{{Name: "deny-all"}}}
+	if err := callbacks.RegisterPolicy(policy); err != nil {
+		t.Fatalf("register policy error: %v", err)
+	}
+	defer callbacks.UnregisterPolicy()
+
+	server := newFakeAPIServer(t)
+	server.addConfigMap("cm", nil)
+	k := server.register(t, "policy-nil")
+	var cm corev1.ConfigMap
+	if err := k.Resource(&cm).Namespace("default").Name("cm").Delete().Error; !callbacks.IsPolicyDenied(err) {
+		t.Fatalf("delete should be denied, got %v", err)
+	}
+
+	// A nil policy clears the rules instead of panicking
+	if err := callbacks.RegisterPolicy(nil); err != nil {
+		t.Fatalf("register nil policy error: %v", err)
+	}
+	if err := k.Resource(&cm).Namespace("default").Name("cm").Delete().Error; callbacks.IsPolicyDenied(err) {
+		t.Fatalf("delete should not be denied after clearing the policy, got %v", err)
+	}
+}
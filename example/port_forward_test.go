@@ -0,0 +1,79 @@
+package example
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestPodPortForward(t *testing.T) {
+	if kom.Clusters().DefaultCluster() == nil {
+		t.Skip("no default cluster")
+	}
+	fw, err := kom.DefaultCluster().
+		Namespace("default").
+		Name("random").Ctl().Pod().
+		PortForward(0, 80)
+	if err != nil {
+		t.Logf("port forward error: %v", err)
+		return
+	}
+	defer fw.Stop()
+
+	ports, err := fw.Ports()
+	if err != nil || len(ports) != 1 || ports[0].Local == 0 {
+		t.Fatalf("expected a local port, got %v %v", ports, err)
+	}
+	fw.Stop()
+	<-fw.Done()
+}
+
+func TestServicePortForwardResolvesPod(t *testing.T) {
+	server := newFakeAPIServer(t)
+	k := server.register(t, "svc-port-forward")
+
+	server.add("services", &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web"},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": "web"},
+			Ports: []corev1.ServicePort{
+				{Name: "http", Port: 80, Protocol: corev1.ProtocolTCP, TargetPort: intstr.FromString("http")},
+			},
+		},
+	})
+	server.add("pods", &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-0", Labels: map[string]string{"app": "web"}},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "web", Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}}},
+		}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	// Without endpoints the pod is found by the selector and the named targetPort by its container ports
+	_, err := k.Namespace("default").Name("web").Ctl().Service().PortForward(0, 80)
+	if err == nil || !strings.Contains(err.Error(), "default/web-0") || !strings.Contains(err.Error(), "0:8080") {
+		t.Fatalf("expected forwarding to web-0:8080 to be attempted, got %v", err)
+	}
+
+	// Endpoints take precedence
+	server.add("endpoints", &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "web"},
+		Subsets: []corev1.EndpointSubset{{
+			Addresses: []corev1.EndpointAddress{{IP: "10.0.0.2", TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "web-1"}}},
+			Ports:     []corev1.EndpointPort{{Name: "http", Port: 9090, Protocol: corev1.ProtocolTCP}},
+		}},
+	})
+	_, err = k.Namespace("default").Name("web").Ctl().Service().PortForward(0, 80)
+	if err == nil || !strings.Contains(err.Error(), "default/web-1") || !strings.Contains(err.Error(), "0:9090") {
+		t.Fatalf("expected forwarding to web-1:9090 to be attempted, got %v", err)
+	}
+
+	_, err = k.Namespace("default").Name("web").Ctl().Service().PortForward(0, 443)
+	if err == nil || !strings.Contains(err.Error(), "does not have port 443") {
+		t.Fatalf("expected unknown port error, got %v", err)
+	}
+}
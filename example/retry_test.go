@@ -0,0 +1,95 @@
+package example
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var fastRetry = kom.RetryPolicy{Attempts: 4, Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond, Jitter: 0.5, Conflicts: true}
+
+func TestRetryPatchOnServerErrors(t *testing.T) {
+	server := newFakeAPIServer(t)
+	server.addConfigMap("cm", map[string]interface{}{"a": "1"})
+	k := server.register(t, "retry-patch")
+
+	server.failNext(http.StatusTooManyRequests, http.StatusServiceUnavailable)
+	var cm corev1.ConfigMap
+	err := k.WithRetry(fastRetry).Resource(&cm).Namespace("default").Name("cm").
+		Patch(&cm, types.MergePatchType, `{"data":{"b":"2"}}`).Error
+	if err != nil {
+		t.Fatalf("patch should succeed after retries: %v", err)
+	}
+	if cm.Data["b"] != "2" {
+		t.Fatalf("unexpected data %v", cm.Data)
+	}
+
+	// Without a retry policy the failure is returned
+	server.failNext(http.StatusServiceUnavailable)
+	err = k.Resource(&cm).Namespace("default").Name("cm").Patch(&cm, types.MergePatchType, `{"data":{"c":"3"}}`).Error
+	if err == nil {
+		t.Fatalf("patch without retry should fail")
+	}
+}
+
+func TestMutateRetriesConflicts(t *testing.T) {
+	server := newFakeAPIServer(t)
+	server.addConfigMap("cm", map[string]interface{}{"count": "0"})
+	k := server.register(t, "retry-mutate")
+
+	var cm corev1.ConfigMap
+	calls := 0
+	err := k.WithRetry(fastRetry).Resource(&cm).Namespace("default").Name("cm").Mutate(&cm, func(obj interface{}) error {
+		calls++
+		if calls == 1 {
+			// Someone else updates the object before our update lands
+			server.touch("cm")
+		}
+		cm.Data["count"] = "1"
+		return nil
+	}).Error
+	if err != nil {
+		t.Fatalf("mutate should succeed after a conflict: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("mutation should be re-applied once, got %d calls", calls)
+	}
+	if cm.Data["count"] != "1" {
+		t.Fatalf("unexpected data %v", cm.Data)
+	}
+}
+
+func TestApplierRetriesOnce(t *testing.T) {
+	server := newFakeAPIServer(t)
+	server.addConfigMap("cm", map[string]interface{}{"a": "1"})
+	k := server.register(t, "retry-applier")
+	doc := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n  namespace: default\ndata:\n  a: \"2\"\n"
+
+	// A stale cached read must not make every attempt conflict
+	var cm corev1.ConfigMap
+	if err := k.WithCache(time.Minute).Resource(&cm).Namespace("default").Name("cm").Get(&cm).Error; err != nil {
+		t.Fatalf("get error: %v", err)
+	}
+	server.touch("cm")
+	results := k.WithCache(time.Minute).WithRetry(fastRetry).Applier().Apply(doc)
+	if len(results) != 1 || !strings.Contains(results[0], "updated") {
+		t.Fatalf("apply should update the live object, got %v", results)
+	}
+
+	// Server errors are retried by the applier only, not again by the update inside it
+	before := server.count(http.MethodPut)
+	server.failNext(http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable,
+		http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	results = k.WithRetry(fastRetry).Applier().Apply(doc)
+	if len(results) != 1 || !strings.Contains(results[0], "error") {
+		t.Fatalf("apply should fail once the attempts are used up, got %v", results)
+	}
+	if puts := server.count(http.MethodPut) - before; puts != fastRetry.Attempts {
+		t.Fatalf("expected %d updates, got %d", fastRetry.Attempts, puts)
+	}
+}
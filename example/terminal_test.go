@@ -0,0 +1,78 @@
+package example
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+)
+
+func TestTerminalResizerKeepsLatestSize(t *testing.T) {
+	resizer := kom.NewTerminalResizer()
+	resizer.Resize(80, 24)
+	resizer.Resize(120, 40)
+
+	size := resizer.Next()
+	if size == nil || size.Width != 120 || size.Height != 40 {
+		t.Fatalf("expected the latest size 120x40, got %v", size)
+	}
+
+	resizer.Close()
+	resizer.Resize(100, 30)
+	if size := resizer.Next(); size != nil {
+		t.Fatalf("expected nil after close, got %v", size)
+	}
+}
+
+func TestInteractiveExecute(t *testing.T) {
+	if kom.Clusters().DefaultCluster() == nil {
+		t.Skip("no default cluster")
+	}
+	var stdout bytes.Buffer
+	term := &kom.Terminal{
+		Stdin:     strings.NewReader("echo kom\nexit 3\n"),
+		Stdout:    &stdout,
+		TTY:       true,
+		SizeQueue: kom.NewTerminalResizer(),
+	}
+	err := kom.DefaultCluster().
+		Namespace("default").
+		Name("random").Ctl().Pod().
+		ContainerName("random").
+		Command("sh").
+		InteractiveExecute(term).Error
+	if err != nil {
+		t.Logf("interactive execute error: %v", err)
+		return
+	}
+	if !term.Exited || term.ExitCode != 3 {
+		t.Fatalf("expected exit code 3, got exited=%v code=%d", term.Exited, term.ExitCode)
+	}
+	if !strings.Contains(stdout.String(), "kom") {
+		t.Fatalf("expected echoed output, got %q", stdout.String())
+	}
+}
+
+func TestAttachRequiresContainer(t *testing.T) {
+	server := newFakeAPIServer(t)
+	k := server.register(t, "attach")
+
+	var called []string
+	err := kom.GlobalCallback().Attach().Before("*").Register("test:attach", func(k *kom.Kubectl) error {
+		called = append(called, k.Statement.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	defer kom.GlobalCallback().Attach().Remove("test:attach")
+
+	err = k.Namespace("default").Name("console").Ctl().Pod().Attach(nil, nil).Error
+	if err == nil || !strings.Contains(err.Error(), "ContainerName") {
+		t.Fatalf("expected a container name error, got %v", err)
+	}
+	if len(called) != 1 || called[0] != "console" {
+		t.Fatalf("expected the attach processor to run hooks, got %v", called)
+	}
+}
@@ -0,0 +1,113 @@
+package example
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+type testSpan struct {
+	name   string
+	attrs  map[string]any
+	err    error
+	ended  bool
+	parent *testSpan
+}
+
+func (s *testSpan) SetAttributes(attrs map[string]any) {
+	for k, v := range attrs {
+		s.attrs[k] = v
+	}
+}
+func (s *testSpan) RecordError(err error) { s.err = err }
+func (s *testSpan) End()                  { s.ended = true }
+
+type spanKey struct{}
+
+// testTracer records spans and propagates the span name as a header
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, kom.Span) {
+	parent, _ := ctx.Value(spanKey{}).(*testSpan)
+	span := &testSpan{name: name, attrs: map[string]any{}, parent: parent}
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (t *testTracer) Inject(ctx context.Context, header http.Header) {
+	if span, ok := ctx.Value(spanKey{}).(*testSpan); ok {
+		header.Set("X-Test-Span", span.name)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	var mu sync.Mutex
+	var propagated []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		propagated = append(propagated, r.Header.Get("X-Test-Span"))
+		mu.Unlock()
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	config := &rest.Config{Host: server.URL}
+	if _, err := kom.Clusters().RegisterByConfigWithID(config, "trace-test", kom.WithLazyInit()); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	defer kom.Clusters().RemoveClusterById("trace-test")
+	if config.WrapTransport != nil {
+		t.Fatalf("the tracing transport should wrap a copy of the caller's config")
+	}
+
+	tracer := &testTracer{}
+	kom.SetTracer(tracer)
+	defer kom.SetTracer(nil)
+
+	root := &testSpan{name: "request", attrs: map[string]any{}}
+	ctx := context.WithValue(context.Background(), spanKey{}, root)
+	var pod corev1.Pod
+	err := kom.Cluster("trace-test").WithContext(ctx).Resource(&pod).Namespace("default").Name("nginx").Delete().Error
+	if err == nil {
+		t.Fatalf("delete should fail against the test server")
+	}
+
+	var span *testSpan
+	for _, s := range tracer.spans {
+		if s.name == "kom.delete" {
+			span = s
+		}
+	}
+	if span == nil {
+		t.Fatalf("no kom.delete span recorded")
+	}
+	if span.parent != root || !span.ended || span.err == nil {
+		t.Fatalf("unexpected span %+v", span)
+	}
+	if span.attrs[kom.AttrCluster] != "trace-test" || span.attrs[kom.AttrName] != "nginx" {
+		t.Fatalf("unexpected span attributes %v", span.attrs)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	found := false
+	for _, name := range propagated {
+		if name == "kom.delete" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("span should be propagated to the API server, got headers %v", propagated)
+	}
+}
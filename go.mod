@@ -9,6 +9,7 @@ require (
 	github.com/duke-git/lancet/v2 v2.3.5
 	github.com/fatih/camelcase v1.0.0
 	github.com/google/gnostic-models v0.6.9
+	github.com/gorilla/websocket v1.5.0
 	github.com/mark3labs/mcp-go v0.17.0
 	github.com/xwb1989/sqlparser v0.0.0-20180606152119-120387863bf2
 	k8s.io/api v0.32.3
@@ -40,7 +41,6 @@ require (
 	github.com/google/gofuzz v1.2.0 // indirect
 	github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510 // indirect
 	github.com/google/uuid v1.6.0 // indirect
-	github.com/gorilla/websocket v1.5.0 // indirect
 	github.com/gregjones/httpcache v0.0.0-20190611155906-901d90724c79 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/josharian/intern v1.0.0 // indirect
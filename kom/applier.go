@@ -68,24 +68,41 @@ func (a *applier) createOrUpdateCRD(obj *unstructured.Unstructured) string {
 		ns = metav1.NamespaceDefault // Default namespace
 		obj.SetNamespace(ns)
 	}
+	var result string
+	// On a conflict the live object is read again and the document re-applied on top of it
+	_ = a.kubectl.retry(a.kubectl.Statement.Retry, true, func() error {
+		var err error
+		result, err = a.applyObject(obj, gvk, ns, name, kind)
+		return err
+	})
+	return result
+}
+
+// attempt returns an instance without retry policy and cache for a single request of createOrUpdateCRD
+// The outer loop already retries, and a cached read would return the stale resourceVersion of a conflict.
+func (a *applier) attempt() *Kubectl {
+	return a.kubectl.newInstance()
+}
+
+func (a *applier) applyObject(obj *unstructured.Unstructured, gvk schema.GroupVersionKind, ns, name, kind string) (string, error) {
 	var cr *unstructured.Unstructured
-	err := a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Namespace(ns).Name(name).Get(&cr).Error
+	err := a.attempt().CRD(gvk.Group, gvk.Version, gvk.Kind).Namespace(ns).Name(name).Get(&cr).Error
 
 	if err == nil && cr != nil && cr.GetName() != "" {
 		// Resource already exists, update it
 		obj.SetResourceVersion(cr.GetResourceVersion())
-		err = a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Name(name).Namespace(ns).Update(&obj).Error
+		err = a.attempt().CRD(gvk.Group, gvk.Version, gvk.Kind).Name(name).Namespace(ns).Update(&obj).Error
 		if err != nil {
-			return fmt.Sprintf("update %s/%s,%s %s/%s error:%v", gvk.Group, gvk.Version, gvk.Kind, ns, name, err)
+			return fmt.Sprintf("update %s/%s,%s %s/%s error:%v", gvk.Group, gvk.Version, gvk.Kind, ns, name, err), err
 		}
-		return fmt.Sprintf("%s/%s updated", kind, name)
+		return fmt.Sprintf("%s/%s updated", kind, name), nil
 	} else {
 		// Resource doesn't exist, create it
-		err = a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Name(name).Namespace(ns).Create(&obj).Error
+		err = a.attempt().CRD(gvk.Group, gvk.Version, gvk.Kind).Name(name).Namespace(ns).Create(&obj).Error
 		if err != nil {
-			return fmt.Sprintf("create %s/%s,%s %s/%s error:%v", gvk.Group, gvk.Version, gvk.Kind, ns, name, err)
+			return fmt.Sprintf("create %s/%s,%s %s/%s error:%v", gvk.Group, gvk.Version, gvk.Kind, ns, name, err), err
 		}
-		return fmt.Sprintf("%s/%s created", kind, name)
+		return fmt.Sprintf("%s/%s created", kind, name), nil
 	}
 }
 func (a *applier) deleteCRD(obj *unstructured.Unstructured) string {
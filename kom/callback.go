@@ -3,22 +3,41 @@ package kom
 import (
 	"fmt"
 	"sort"
+	"sync"
+	"time"
 
 	"k8s.io/klog/v2"
 )
 
+// CallbackPhase is the stage of an operation a callback runs in
+type CallbackPhase string
+
+const (
+	PhaseBefore       CallbackPhase = "before"        // Runs before the operation, an error skips the operation
+	PhaseMain         CallbackPhase = "main"          // The operation itself, default phase of Register
+	PhaseAfterSuccess CallbackPhase = "after-success" // Runs when the operation succeeded, an error fails the operation
+	PhaseAfterError   CallbackPhase = "after-error"   // Runs when the operation failed, errors are only logged
+	PhaseFinally      CallbackPhase = "finally"       // Always runs last, errors are only logged
+)
+
+// callbackPhases lists the phases in execution order
+var callbackPhases = []CallbackPhase{PhaseBefore, PhaseMain, PhaseAfterSuccess, PhaseAfterError, PhaseFinally}
+
 // callbacks km callbacks manager
 type callbacks struct {
 	processors map[string]*processor
 }
 
 type processor struct {
+	mu        sync.RWMutex // Guards fns and callbacks, callbacks may be registered while operations run
+	name      string
 	km        *Kubectl
-	fns       []func(*Kubectl) error
+	fns       map[CallbackPhase][]func(*Kubectl) error
 	callbacks []*callback
 }
 type callback struct {
 	name      string
+	phase     CallbackPhase
 	before    string
 	after     string
 	remove    bool
@@ -28,21 +47,40 @@ type callback struct {
 }
 
 func (k *Kubectl) initializeCallbacks() *callbacks {
-	return &callbacks{
+	cs := &callbacks{
 		processors: map[string]*processor{
-			"get":         {km: k},
-			"patch":       {km: k},
-			"create":      {km: k},
-			"update":      {km: k},
-			"delete":      {km: k},
-			"list":        {km: k},
-			"exec":        {km: k},
-			"logs":        {km: k},
-			"watch":       {km: k},
-			"describe":    {km: k},
-			"stream-exec": {km: k},
+			"get":          {km: k},
+			"patch":        {km: k},
+			"create":       {km: k},
+			"update":       {km: k},
+			"delete":       {km: k},
+			"list":         {km: k},
+			"exec":         {km: k},
+			"logs":         {km: k},
+			"watch":        {km: k},
+			"describe":     {km: k},
+			"stream-exec":  {km: k},
+			"port-forward": {km: k},
+			"attach":       {km: k},
 		},
 	}
+	for name, p := range cs.processors {
+		p.name = name
+	}
+	return cs
+}
+
+// unavailableCallbacks returns processors that fail every operation with err
+func unavailableCallbacks(k *Kubectl, err error) *callbacks {
+	cs := k.initializeCallbacks()
+	for _, p := range cs.processors {
+		p.fns = map[CallbackPhase][]func(*Kubectl) error{
+			PhaseMain: {func(*Kubectl) error {
+				return err
+			}},
+		}
+	}
+	return cs
 }
 
 func (cs *callbacks) Create() *processor {
@@ -73,6 +111,12 @@ func (cs *callbacks) Exec() *processor {
 func (cs *callbacks) StreamExec() *processor {
 	return cs.processors["stream-exec"]
 }
+func (cs *callbacks) PortForward() *processor {
+	return cs.processors["port-forward"]
+}
+func (cs *callbacks) Attach() *processor {
+	return cs.processors["attach"]
+}
 func (cs *callbacks) Logs() *processor {
 	return cs.processors["logs"]
 }
@@ -80,6 +124,8 @@ func (cs *callbacks) Watch() *processor {
 	return cs.processors["watch"]
 }
 func (c *callback) Remove(name string) error {
+	c.processor.mu.Lock()
+	defer c.processor.mu.Unlock()
 	klog.V(4).Infof("removing callback `%s` \n", name)
 	c.name = name
 	c.remove = true
@@ -89,13 +135,33 @@ func (c *callback) Remove(name string) error {
 
 func (c *callback) Replace(name string, fn func(*Kubectl) error) error {
 	klog.V(4).Infof("replacing callback `%s` \n", name)
+	if c.phase != "" && !validPhase(c.phase) {
+		return fmt.Errorf("unknown phase %s of callback %s", c.phase, name)
+	}
+	c.processor.mu.Lock()
+	defer c.processor.mu.Unlock()
 	c.name = name
+	if c.phase == "" {
+		// Keep the phase of the replaced callback
+		for i := len(c.processor.callbacks) - 1; i >= 0; i-- {
+			if v := c.processor.callbacks[i]; v.name == name && !v.remove {
+				c.phase = v.phase
+				break
+			}
+		}
+	}
 	c.handler = fn
 	c.replace = true
 	c.processor.callbacks = append(c.processor.callbacks, c)
 	return c.processor.compile()
 }
 
+// Phase sets the stage of the operation the callback runs in
+func (c *callback) Phase(phase CallbackPhase) *callback {
+	c.phase = phase
+	return c
+}
+
 func (c *callback) Before(name string) *callback {
 	c.before = name
 	return c
@@ -107,6 +173,11 @@ func (c *callback) After(name string) *callback {
 }
 
 func (c *callback) Register(name string, fn func(*Kubectl) error) error {
+	if c.phase != "" && !validPhase(c.phase) {
+		return fmt.Errorf("unknown phase %s of callback %s", c.phase, name)
+	}
+	c.processor.mu.Lock()
+	defer c.processor.mu.Unlock()
 	c.name = name
 	c.handler = fn
 	c.processor.callbacks = append(c.processor.callbacks, c)
@@ -114,6 +185,8 @@ func (c *callback) Register(name string, fn func(*Kubectl) error) error {
 }
 
 func (p *processor) Get(name string) func(*Kubectl) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	for i := len(p.callbacks) - 1; i >= 0; i-- {
 		if v := p.callbacks[i]; v.name == name && !v.remove {
 			return v.handler
@@ -130,6 +203,9 @@ func (p *processor) Replace(name string, fn func(*Kubectl) error) error {
 	return (&callback{processor: p}).Replace(name, fn)
 }
 
+// Execute runs the callbacks of every phase
+// Before and main callbacks stop at the first error. After callbacks can read the outcome
+// through k.Error, k.Statement.Duration and k.Statement.RowsAffected.
 func (p *processor) Execute(k *Kubectl) error {
 	// // Perform necessary checks before execution
 	// if k.Statement.GVR.Empty() {
@@ -137,15 +213,93 @@ func (p *processor) Execute(k *Kubectl) error {
 	// 	return k.Statement.Error
 	// }
 
-	for _, f := range p.fns {
-		err := f(k)
-		if err != nil {
+	// Bind the operation to the cluster context, so closing the cluster cancels it
+	ctx, cancel := k.withClusterContext(k.Statement.Context)
+	origin := k.Statement.Context
+	k.Statement.Context = ctx
+	defer func() {
+		k.Statement.Context = origin
+		if p.streaming() && k.Error == nil {
+			// The stream outlives Execute, its end releases the context
+			k.releaseWhenStreamEnds(cancel)
+			return
+		}
+		cancel()
+	}()
+
+	// Requests of the operation become children of its span
+	ctx, span := k.startSpan("kom." + p.name)
+	if span != nil {
+		span.SetAttributes(map[string]any{AttrOperation: p.name})
+		k.Statement.Context = ctx
+	}
+
+	p.mu.RLock()
+	fns := p.fns
+	p.mu.RUnlock()
+
+	k.Statement.StartTime = time.Now()
+	k.Statement.Duration = 0
+	k.Statement.CacheStatus = ""
+
+	var err error
+	// Never fall back to the cluster's own identity when impersonation was requested
+	if k.Statement.Impersonation != nil && k.Statement.impersonated == nil {
+		err = fmt.Errorf("impersonation of %s is not available on cluster %s", k.Statement.Impersonation.UserName, k.ID)
+	}
+	if err == nil {
+		err = runPhase(fns, k, PhaseBefore)
+	}
+	if err == nil {
+		err = runPhase(fns, k, PhaseMain)
+	}
+	k.Statement.Duration = time.Since(k.Statement.StartTime)
+
+	if err == nil {
+		err = runPhase(fns, k, PhaseAfterSuccess)
+	}
+	k.Error = err
+	if err != nil {
+		p.runAll(fns, k, PhaseAfterError)
+	}
+	p.runAll(fns, k, PhaseFinally)
+	k.endSpan(span, err)
+	return err
+}
+
+// runPhase executes the callbacks of a phase, stopping at the first error
+func runPhase(fns map[CallbackPhase][]func(*Kubectl) error, k *Kubectl, phase CallbackPhase) error {
+	for _, f := range fns[phase] {
+		if err := f(k); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// runAll executes every callback of a phase, errors are logged and do not change the outcome
+func (p *processor) runAll(fns map[CallbackPhase][]func(*Kubectl) error, k *Kubectl, phase CallbackPhase) {
+	for _, f := range fns[phase] {
+		if err := f(k); err != nil {
+			klog.V(2).Infof("%s %s callback on cluster %s error: %v", p.name, phase, k.ID, err)
+		}
+	}
+}
+
+// streaming reports whether the processor hands back a stream that outlives Execute
+// Their context is cancelled when the stream ends, by the caller or when the cluster is closed
+func (p *processor) streaming() bool {
+	return p.name == "watch" || p.name == "logs" || p.name == "port-forward"
+}
+
+// Phase selects the stage of the operation the next registered callback runs in
+//
+// Example:
+// k.Callback().Delete().Phase(kom.PhaseFinally).Register("audit", fn)
+func (p *processor) Phase(phase CallbackPhase) *callback {
+	return &callback{phase: phase, processor: p}
+}
+
 func (p *processor) Before(name string) *callback {
 	return &callback{before: name, processor: p}
 }
@@ -173,9 +327,25 @@ func (p *processor) compile() (err error) {
 	}
 	p.callbacks = callbacks
 
-	if p.fns, err = sortCallbacks(p.callbacks); err != nil {
-		klog.V(4).Infof("Got error when compile callbacks, got %v", err)
+	// Callbacks are ordered within their own phase
+	byPhase := map[CallbackPhase][]*callback{}
+	for _, cb := range p.callbacks {
+		if cb.phase == "" {
+			cb.phase = PhaseMain
+		}
+		byPhase[cb.phase] = append(byPhase[cb.phase], cb)
 	}
+	fns := map[CallbackPhase][]func(*Kubectl) error{}
+	for _, phase := range callbackPhases {
+		if len(byPhase[phase]) == 0 {
+			continue
+		}
+		if fns[phase], err = sortCallbacks(byPhase[phase]); err != nil {
+			klog.V(4).Infof("Got error when compile %s callbacks, got %v", phase, err)
+			return
+		}
+	}
+	p.fns = fns
 	return
 }
 func sortCallbacks(cs []*callback) (fns []func(*Kubectl) error, err error) {
@@ -274,6 +444,15 @@ func sortCallbacks(cs []*callback) (fns []func(*Kubectl) error, err error) {
 	return
 }
 
+func validPhase(phase CallbackPhase) bool {
+	for _, p := range callbackPhases {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
+
 func removeCallbacks(cs []*callback, nameMap map[string]bool) []*callback {
 	callbacks := make([]*callback, 0, len(cs))
 	for _, callback := range cs {
@@ -0,0 +1,210 @@
+package kom
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// globalCallbackOp is a recorded change to the callbacks of every cluster
+// Ops are replayed in order on clusters registered later.
+type globalCallbackOp struct {
+	processor string
+	name      string
+	phase     CallbackPhase
+	before    string
+	after     string
+	remove    bool
+	replace   bool
+	handler   func(*Kubectl) error
+}
+
+// globalCallbacks holds callbacks applied to every current and future cluster
+type globalCallbacks struct {
+	mu      sync.Mutex
+	ops     []*globalCallbackOp
+	applied map[*ClusterInst]int // Number of ops already applied per cluster
+}
+
+var globalCallbackRegistry = &globalCallbacks{applied: map[*ClusterInst]int{}}
+
+// GlobalCallback manages callbacks that apply to every registered cluster, including clusters registered later
+//
+// Example:
+// kom.GlobalCallback().Delete().Phase(kom.PhaseFinally).After("kom:delete").Register("audit", fn)
+func GlobalCallback() *globalCallbacks {
+	return globalCallbackRegistry
+}
+
+type globalProcessor struct {
+	registry *globalCallbacks
+	name     string
+}
+
+type globalCallback struct {
+	processor *globalProcessor
+	phase     CallbackPhase
+	before    string
+	after     string
+}
+
+func (g *globalCallbacks) processor(name string) *globalProcessor {
+	return &globalProcessor{registry: g, name: name}
+}
+
+// Processor selects a processor by name, such as "delete" or "stream-exec"
+func (g *globalCallbacks) Processor(name string) *globalProcessor {
+	return g.processor(name)
+}
+
+func (g *globalCallbacks) Get() *globalProcessor {
+	return g.processor("get")
+}
+func (g *globalCallbacks) List() *globalProcessor {
+	return g.processor("list")
+}
+func (g *globalCallbacks) Create() *globalProcessor {
+	return g.processor("create")
+}
+func (g *globalCallbacks) Update() *globalProcessor {
+	return g.processor("update")
+}
+func (g *globalCallbacks) Patch() *globalProcessor {
+	return g.processor("patch")
+}
+func (g *globalCallbacks) Delete() *globalProcessor {
+	return g.processor("delete")
+}
+func (g *globalCallbacks) Describe() *globalProcessor {
+	return g.processor("describe")
+}
+func (g *globalCallbacks) Exec() *globalProcessor {
+	return g.processor("exec")
+}
+func (g *globalCallbacks) StreamExec() *globalProcessor {
+	return g.processor("stream-exec")
+}
+func (g *globalCallbacks) PortForward() *globalProcessor {
+	return g.processor("port-forward")
+}
+func (g *globalCallbacks) Attach() *globalProcessor {
+	return g.processor("attach")
+}
+func (g *globalCallbacks) Logs() *globalProcessor {
+	return g.processor("logs")
+}
+func (g *globalCallbacks) Watch() *globalProcessor {
+	return g.processor("watch")
+}
+
+func (p *globalProcessor) Phase(phase CallbackPhase) *globalCallback {
+	return &globalCallback{processor: p, phase: phase}
+}
+func (p *globalProcessor) Before(name string) *globalCallback {
+	return &globalCallback{processor: p, before: name}
+}
+func (p *globalProcessor) After(name string) *globalCallback {
+	return &globalCallback{processor: p, after: name}
+}
+func (p *globalProcessor) Register(name string, fn func(*Kubectl) error) error {
+	return (&globalCallback{processor: p}).Register(name, fn)
+}
+func (p *globalProcessor) Replace(name string, fn func(*Kubectl) error) error {
+	return (&globalCallback{processor: p}).Replace(name, fn)
+}
+func (p *globalProcessor) Remove(name string) error {
+	return (&globalCallback{processor: p}).Remove(name)
+}
+
+func (c *globalCallback) Phase(phase CallbackPhase) *globalCallback {
+	c.phase = phase
+	return c
+}
+func (c *globalCallback) Before(name string) *globalCallback {
+	c.before = name
+	return c
+}
+func (c *globalCallback) After(name string) *globalCallback {
+	c.after = name
+	return c
+}
+
+// Register adds the callback to every cluster
+func (c *globalCallback) Register(name string, fn func(*Kubectl) error) error {
+	return c.record(&globalCallbackOp{name: name, handler: fn})
+}
+
+// Replace replaces the named callback on every cluster
+func (c *globalCallback) Replace(name string, fn func(*Kubectl) error) error {
+	return c.record(&globalCallbackOp{name: name, handler: fn, replace: true})
+}
+
+// Remove removes the named callback from every cluster
+func (c *globalCallback) Remove(name string) error {
+	return c.record(&globalCallbackOp{name: name, remove: true})
+}
+
+func (c *globalCallback) record(op *globalCallbackOp) error {
+	if c.phase != "" && !validPhase(c.phase) {
+		return fmt.Errorf("unknown phase %s of callback %s", c.phase, op.name)
+	}
+	if _, ok := (&Kubectl{}).initializeCallbacks().processors[c.processor.name]; !ok {
+		return fmt.Errorf("unknown callback processor %s", c.processor.name)
+	}
+	op.processor = c.processor.name
+	op.phase = c.phase
+	op.before = c.before
+	op.after = c.after
+
+	g := c.processor.registry
+	g.mu.Lock()
+	g.ops = append(g.ops, op)
+	g.mu.Unlock()
+
+	var errs []error
+	for _, cluster := range Clusters().AllClusters() {
+		if err := g.applyTo(cluster); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyTo replays the ops a cluster has not seen yet
+func (g *globalCallbacks) applyTo(cluster *ClusterInst) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if cluster.Closed() {
+		delete(g.applied, cluster)
+		return nil
+	}
+
+	var errs []error
+	for _, op := range g.ops[g.applied[cluster]:] {
+		if err := op.apply(cluster.callbacks); err != nil {
+			errs = append(errs, fmt.Errorf("apply global %s callback %s to cluster %s error: %v", op.processor, op.name, cluster.ID, err))
+		}
+	}
+	g.applied[cluster] = len(g.ops)
+	return errors.Join(errs...)
+}
+
+// forget drops the bookkeeping of a removed cluster
+func (g *globalCallbacks) forget(cluster *ClusterInst) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.applied, cluster)
+}
+
+func (op *globalCallbackOp) apply(cs *callbacks) error {
+	p := cs.processors[op.processor]
+	cb := &callback{processor: p, phase: op.phase, before: op.before, after: op.after}
+	switch {
+	case op.remove:
+		return cb.Remove(op.name)
+	case op.replace:
+		return cb.Replace(op.name, op.handler)
+	default:
+		return cb.Register(op.name, op.handler)
+	}
+}
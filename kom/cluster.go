@@ -1,8 +1,11 @@
 package kom
 
 import (
+	"container/list"
 	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dgraph-io/ristretto/v2"
@@ -24,8 +27,11 @@ var clusterInstances *ClusterInstances
 
 // ClusterInstances manages multiple cluster instances
 type ClusterInstances struct {
+	mu                   sync.RWMutex // Guards clusters
 	clusters             map[string]*ClusterInst
-	callbackRegisterFunc func(cluster *ClusterInst) func() // Callback function for registering parameters
+	callbackRegisterFunc func(cluster *ClusterInst) func()   // Callback function for registering parameters
+	callbackRegisterFns  []func(cluster *ClusterInst) func() // Additional callback registration functions
+	store                ClusterStore                        // Persists registered clusters, optional
 }
 
 // ClusterInst represents a single cluster instance
@@ -35,6 +41,7 @@ type ClusterInst struct {
 	Client        *kubernetes.Clientset        // Kubernetes client
 	Config        *rest.Config                 // REST config
 	DynamicClient *dynamic.DynamicClient       // Dynamic client
+	Options       RegisterOptions              // Options used when registering
 	apiResources  []*metav1.APIResource        // Currently registered k8s resources
 	crdList       []*unstructured.Unstructured // Currently registered k8s CRDs //TODO Update periodically or via Watch
 	callbacks     *callbacks                   // Callbacks
@@ -43,6 +50,27 @@ type ClusterInst struct {
 	describerMap  map[schema.GroupKind]describe.ResourceDescriber
 	Cache         *ristretto.Cache[string, any]
 	openAPISchema *openapi_v2.Document // OpenAPI schema
+
+	apiResourcesOnce  lazyOnce    // Loads apiResources
+	crdListOnce       lazyOnce    // Loads crdList
+	serverVersionOnce lazyOnce    // Loads serverVersion
+	openAPISchemaOnce lazyOnce    // Loads openAPISchema
+	docsOnce          lazyOnce    // Builds docs
+	describerMapOnce  lazyOnce    // Builds describerMap
+	initialized       atomic.Bool // Whether the server version has been loaded
+
+	impersonatedMu      sync.Mutex               // Guards impersonatedClients and impersonatedLRU
+	impersonatedClients map[string]*list.Element // Clients per impersonated identity, elements of impersonatedLRU
+	impersonatedLRU     *list.List               // Impersonated clients, most recently used first
+
+	ctx     context.Context    // Cluster-scoped context, cancelled on Close
+	cancel  context.CancelFunc // Cancels ctx
+	closeMu sync.Mutex         // Guards closers
+	closers []func()           // Run on Close
+	closed  atomic.Bool        // Whether Close has been called
+
+	sourceMu sync.RWMutex   // Guards source and Options.Labels
+	source   *ClusterSource // Where the cluster was discovered from
 }
 
 // Clusters returns the cluster instances manager
@@ -77,30 +105,41 @@ func Cluster(id string) *Kubectl {
 }
 
 // RegisterInCluster registers an InCluster configuration
-func (c *ClusterInstances) RegisterInCluster() (*Kubectl, error) {
+func (c *ClusterInstances) RegisterInCluster(opts ...RegisterOption) (*Kubectl, error) {
 	config, err := rest.InClusterConfig()
 	if err != nil {
 		return nil, fmt.Errorf("InCluster Error %v", err)
 	}
-	return c.RegisterByConfigWithID(config, "InCluster")
+	return c.RegisterByConfigWithID(config, "InCluster", opts...)
 }
 
 // SetRegisterCallbackFunc sets the callback registration function
+// It replaces the previous one, use AddRegisterCallbackFunc or GlobalCallback to add callbacks alongside it.
 func (c *ClusterInstances) SetRegisterCallbackFunc(callback func(cluster *ClusterInst) func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.callbackRegisterFunc = callback
 }
 
+// AddRegisterCallbackFunc adds a callback registration function, run after the one set by SetRegisterCallbackFunc
+// It runs for every cluster registered afterwards.
+func (c *ClusterInstances) AddRegisterCallbackFunc(callback func(cluster *ClusterInst) func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.callbackRegisterFns = append(c.callbackRegisterFns, callback)
+}
+
 // RegisterByPath registers a cluster using a kubeconfig file path
-func (c *ClusterInstances) RegisterByPath(path string) (*Kubectl, error) {
+func (c *ClusterInstances) RegisterByPath(path string, opts ...RegisterOption) (*Kubectl, error) {
 	config, err := clientcmd.BuildConfigFromFlags("", path)
 	if err != nil {
 		return nil, fmt.Errorf("RegisterByPath Error %s %v", path, err)
 	}
-	return c.RegisterByConfig(config)
+	return c.RegisterByConfig(config, opts...)
 }
 
 // RegisterByString registers a cluster using the string content of a kubeconfig file
-func (c *ClusterInstances) RegisterByString(str string) (*Kubectl, error) {
+func (c *ClusterInstances) RegisterByString(str string, opts ...RegisterOption) (*Kubectl, error) {
 	config, err := clientcmd.Load([]byte(str))
 	if err != nil {
 		return nil, fmt.Errorf("RegisterByString Error,content=:\n%s\n,err:%v", str, err)
@@ -111,11 +150,11 @@ func (c *ClusterInstances) RegisterByString(str string) (*Kubectl, error) {
 	if err != nil {
 		return nil, err
 	}
-	return c.RegisterByConfig(restConfig)
+	return c.RegisterByConfig(restConfig, append(opts, withKubeconfig(str))...)
 }
 
 // RegisterByStringWithID registers a cluster using the string content of a kubeconfig file with a specific ID
-func (c *ClusterInstances) RegisterByStringWithID(str string, id string) (*Kubectl, error) {
+func (c *ClusterInstances) RegisterByStringWithID(str string, id string, opts ...RegisterOption) (*Kubectl, error) {
 	config, err := clientcmd.Load([]byte(str))
 	if err != nil {
 		return nil, fmt.Errorf("RegisterByStringWithID Error content=\n%s\n,id:%s,err:%v", str, id, err)
@@ -126,82 +165,210 @@ func (c *ClusterInstances) RegisterByStringWithID(str string, id string) (*Kubec
 	if err != nil {
 		return nil, err
 	}
-	return c.RegisterByConfigWithID(restConfig, id)
+	return c.RegisterByConfigWithID(restConfig, id, append(opts, withKubeconfig(str))...)
 }
 
 // RegisterByPathWithID registers a cluster using a kubeconfig file path with a specific ID
-func (c *ClusterInstances) RegisterByPathWithID(path string, id string) (*Kubectl, error) {
+func (c *ClusterInstances) RegisterByPathWithID(path string, id string, opts ...RegisterOption) (*Kubectl, error) {
 	config, err := clientcmd.BuildConfigFromFlags("", path)
 	if err != nil {
 		return nil, fmt.Errorf("RegisterByPathWithID Error path:%s,id:%s,err:%v", path, id, err)
 	}
-	return c.RegisterByConfigWithID(config, id)
+	return c.RegisterByConfigWithID(config, id, opts...)
 }
 
 // RegisterByConfig registers a cluster using a REST config
-func (c *ClusterInstances) RegisterByConfig(config *rest.Config) (*Kubectl, error) {
+func (c *ClusterInstances) RegisterByConfig(config *rest.Config, opts ...RegisterOption) (*Kubectl, error) {
 	if config == nil {
 		return nil, fmt.Errorf("config is nil")
 	}
 	host := config.Host
 
-	return c.RegisterByConfigWithID(config, host)
+	return c.RegisterByConfigWithID(config, host, opts...)
 }
 
 // RegisterByConfigWithID registers a cluster using a REST config with a specific ID
-func (c *ClusterInstances) RegisterByConfigWithID(config *rest.Config, id string) (*Kubectl, error) {
+func (c *ClusterInstances) RegisterByConfigWithID(config *rest.Config, id string, opts ...RegisterOption) (*Kubectl, error) {
 	if config == nil {
 		return nil, fmt.Errorf("config is nil")
 	}
 	config.QPS = 200
 	config.Burst = 2000
-	cluster, exists := clusterInstances.clusters[id]
-	if exists {
+	if cluster := c.GetClusterById(id); cluster != nil {
 		return cluster.Kubectl, nil
-	} else {
-		// Initialize when key doesn't exist
-		k := initKubectl(config, id)
-		cluster = &ClusterInst{
-			ID:      id,
-			Kubectl: k,
-			Config:  config,
-		}
-		clusterInstances.clusters[id] = cluster
+	}
+	config = withTracingTransport(config)
 
-		client, err := kubernetes.NewForConfig(config)
-		if err != nil {
-			return nil, fmt.Errorf("RegisterByConfigWithID Error %s %v", id, err)
-		}
-		dynamicClient, err := dynamic.NewForConfig(config)
-		if err != nil {
-			return nil, fmt.Errorf("RegisterByConfigWithID Error %s %v", id, err)
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("RegisterByConfigWithID Error %s %v", id, err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("RegisterByConfigWithID Error %s %v", id, err)
+	}
+	// Cache, created first because the CRD list is loaded through it
+	cache, err := ristretto.NewCache(&ristretto.Config[string, any]{
+		NumCounters: 1e7,     // number of keys to track frequency of (10M)
+		MaxCost:     1 << 30, // maximum cost of cache (1GB)
+		BufferItems: 64,      // number of keys per Get buffer
+	})
+	if err != nil {
+		return nil, fmt.Errorf("RegisterByConfigWithID Error %s %v", id, err)
+	}
+
+	// Initialize when key doesn't exist
+	ctx, cancel := context.WithCancel(context.Background())
+	k := initKubectl(config, id, ctx)
+	cluster := &ClusterInst{
+		ID:            id,
+		Kubectl:       k,
+		Config:        config,
+		Options:       newRegisterOptions(opts),
+		Client:        client,        // Kubernetes client
+		DynamicClient: dynamicClient, // Dynamic client
+		Cache:         cache,
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+	cluster.source = cluster.Options.source
+	cluster.callbacks = k.initializeCallbacks() // Callbacks
+
+	c.mu.Lock()
+	if exists, ok := c.clusters[id]; ok {
+		// Registered concurrently, keep the first one
+		c.mu.Unlock()
+		_ = cluster.Close()
+		return exists.Kubectl, nil
+	}
+	c.clusters[id] = cluster
+	c.mu.Unlock()
+
+	if !cluster.Options.Lazy {
+		// Load everything up front, lazy clusters load each piece on first use
+		cluster.getAPIResources()  // API resources
+		cluster.getCRDList()       // CRD list with 10-minute cache
+		cluster.getServerVersion() // Server version
+		cluster.getOpenAPISchema() // OpenAPI schema
+		cluster.getDocs()          // Documentation
+		cluster.getDescriberMap()  // Initialize describers
+	}
+	c.mu.RLock()
+	registerFns := append([]func(cluster *ClusterInst) func(){c.callbackRegisterFunc}, c.callbackRegisterFns...)
+	c.mu.RUnlock()
+	for _, fn := range registerFns {
+		if fn != nil { // Register callback method
+			fn(cluster)
 		}
-		cluster.Client = client               // Kubernetes client
-		cluster.DynamicClient = dynamicClient // Dynamic client
-		// Cache
-		cluster.apiResources = k.initializeAPIResources()       // API resources
-		cluster.crdList = k.initializeCRDList(time.Minute * 10) // CRD list with 10-minute cache
-		cluster.callbacks = k.initializeCallbacks()             // Callbacks
-		cluster.serverVersion = k.initializeServerVersion()     // Server version
-		cluster.openAPISchema = k.getOpenAPISchema()
-		cluster.docs = doc.InitTrees(k.getOpenAPISchema()) // Documentation
-		cluster.describerMap = k.initializeDescriberMap()  // Initialize describers
-		if c.callbackRegisterFunc != nil {                 // Register callback method
-			c.callbackRegisterFunc(cluster)
+	}
+	if err := globalCallbackRegistry.applyTo(cluster); err != nil {
+		klog.Errorf("%v", err)
+	}
+	c.persist(cluster)
+	return k, nil
+}
+
+// lazyOnce is a sync.Once that only counts successful loads
+// A load that fails, e.g. while the cluster is not reachable, is tried again on the next use.
+type lazyOnce struct {
+	done atomic.Bool
+	mu   sync.Mutex
+}
+
+// Do runs load unless an earlier call succeeded, load reports whether it succeeded
+func (o *lazyOnce) Do(load func() bool) {
+	if o.done.Load() {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.done.Load() && load() {
+		o.done.Store(true)
+	}
+}
+
+// getAPIResources returns the API resources, loading them on first use
+// All getters return nil once the cluster has been removed
+func (c *ClusterInst) getAPIResources() []*metav1.APIResource {
+	if c == nil {
+		return nil
+	}
+	c.apiResourcesOnce.Do(func() bool {
+		c.apiResources = c.Kubectl.initializeAPIResources()
+		return len(c.apiResources) > 0
+	})
+	return c.apiResources
+}
+
+// getCRDList returns the CRD list, loading it on first use
+func (c *ClusterInst) getCRDList() []*unstructured.Unstructured {
+	if c == nil {
+		return nil
+	}
+	c.crdListOnce.Do(func() bool {
+		c.crdList = c.Kubectl.initializeCRDList(time.Minute * 10)
+		return c.crdList != nil
+	})
+	return c.crdList
+}
+
+// getServerVersion returns the server version, loading it on first use
+func (c *ClusterInst) getServerVersion() *version.Info {
+	if c == nil {
+		return nil
+	}
+	c.serverVersionOnce.Do(func() bool {
+		c.serverVersion = c.Kubectl.initializeServerVersion()
+		if c.serverVersion == nil {
+			return false
 		}
+		c.initialized.Store(true)
+		return true
+	})
+	return c.serverVersion
+}
+
+// getOpenAPISchema returns the OpenAPI v2 document, loading it on first use
+func (c *ClusterInst) getOpenAPISchema() *openapi_v2.Document {
+	if c == nil {
+		return nil
+	}
+	c.openAPISchemaOnce.Do(func() bool {
+		c.openAPISchema = c.Kubectl.getOpenAPISchema()
+		return c.openAPISchema != nil
+	})
+	return c.openAPISchema
+}
 
-		cache, err := ristretto.NewCache(&ristretto.Config[string, any]{
-			NumCounters: 1e7,     // number of keys to track frequency of (10M)
-			MaxCost:     1 << 30, // maximum cost of cache (1GB)
-			BufferItems: 64,      // number of keys per Get buffer
-		})
-		cluster.Cache = cache
-		return k, nil
+// getDocs returns the doc trees, building them from the OpenAPI document on first use
+func (c *ClusterInst) getDocs() *doc.Docs {
+	if c == nil {
+		return nil
+	}
+	c.docsOnce.Do(func() bool {
+		schema := c.getOpenAPISchema()
+		c.docs = doc.InitTrees(schema)
+		return schema != nil
+	})
+	return c.docs
+}
+
+// getDescriberMap returns the describers, building them on first use
+func (c *ClusterInst) getDescriberMap() map[schema.GroupKind]describe.ResourceDescriber {
+	if c == nil {
+		return nil
 	}
+	c.describerMapOnce.Do(func() bool {
+		c.describerMap = c.Kubectl.initializeDescriberMap()
+		return c.describerMap != nil
+	})
+	return c.describerMap
 }
 
 // GetClusterById gets a cluster instance by ID
 func (c *ClusterInstances) GetClusterById(id string) *ClusterInst {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	cluster, exists := c.clusters[id]
 	if !exists {
 		return nil
@@ -209,14 +376,58 @@ func (c *ClusterInstances) GetClusterById(id string) *ClusterInst {
 	return cluster
 }
 
-// RemoveClusterById removes a cluster by ID
-func (c *ClusterInstances) RemoveClusterById(id string) {
+// RemoveClusterById unregisters a cluster, closes it and forgets it
+// The cluster is also deleted from the store, if one is set, so it is not restored on the next start.
+// Returns an error if the cluster is unknown
+func (c *ClusterInstances) RemoveClusterById(id string) error {
+	cluster, store, err := c.unregister(id)
+	if err != nil {
+		return err
+	}
+	if store != nil {
+		if err := store.Delete(id); err != nil {
+			klog.Errorf("delete cluster %s from store error: %v", id, err)
+		}
+	}
+	return cluster.Close()
+}
+
+// CloseClusterById unregisters a cluster and closes it, keeping its store record
+// Use it on shutdown, LoadFromStore restores the cluster on the next start.
+// Returns an error if the cluster is unknown
+func (c *ClusterInstances) CloseClusterById(id string) error {
+	cluster, _, err := c.unregister(id)
+	if err != nil {
+		return err
+	}
+	return cluster.Close()
+}
+
+// unregister removes a cluster from the registered clusters and returns it with the current store
+func (c *ClusterInstances) unregister(id string) (*ClusterInst, ClusterStore, error) {
+	c.mu.Lock()
+	cluster, exists := c.clusters[id]
+	if !exists {
+		c.mu.Unlock()
+		return nil, nil, fmt.Errorf("cluster %s not found", id)
+	}
 	delete(c.clusters, id)
+	store := c.store
+	c.mu.Unlock()
+	globalCallbackRegistry.forget(cluster)
+	return cluster, store, nil
 }
 
 // AllClusters returns all cluster instances
+// The returned map is a snapshot, changing it does not register or remove clusters
 func (c *ClusterInstances) AllClusters() map[string]*ClusterInst {
-	return c.clusters
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	clusters := make(map[string]*ClusterInst, len(c.clusters))
+	for id, cluster := range c.clusters {
+		clusters[id] = cluster
+	}
+	return clusters
 }
 
 // DefaultCluster returns a default ClusterInst instance.
@@ -225,6 +436,8 @@ func (c *ClusterInstances) AllClusters() map[string]*ClusterInst {
 // then tries to return the instance with ID "default".
 // If neither exists, returns any instance from the clusters list.
 func (c *ClusterInstances) DefaultCluster() *ClusterInst {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	// Check if clusters list is empty
 	if len(c.clusters) == 0 {
 		return nil
@@ -261,27 +474,13 @@ func (c *ClusterInstances) RegisterClusterAPIConfigs() error {
 		return fmt.Errorf("no default cluster available to discover CAPI clusters")
 	}
 
-	// Define supported CAPI versions
-	capiVersions := []schema.GroupVersionResource{
-		{
-			Group:    "cluster.x-k8s.io",
-			Version:  "v1beta1",
-			Resource: "clusters",
-		},
-		{
-			Group:    "cluster.x-k8s.io",
-			Version:  "v1alpha3",
-			Resource: "clusters",
-		},
-	}
-
 	// Track if we found any clusters across all versions
 	foundClusters := false
 
 	// Iterate through each CAPI version
 	for _, capiGVR := range capiVersions {
 		// List all namespaces with CAPI clusters for this version
-		clusters, err := defaultCluster.DynamicClient.Resource(capiGVR).List(context.Background(), metav1.ListOptions{})
+		clusters, err := defaultCluster.DynamicClient.Resource(capiGVR).List(defaultCluster.ctx, metav1.ListOptions{})
 		if err != nil {
 			klog.V(4).Infof("CAPI version %s not available: %v", capiGVR.Version, err)
 			continue // Try next version if this one isn't available
@@ -292,12 +491,13 @@ func (c *ClusterInstances) RegisterClusterAPIConfigs() error {
 			foundClusters = true
 		}
 
-		for _, cluster := range clusters.Items {
+		for i := range clusters.Items {
+			cluster := &clusters.Items[i]
 			clusterName := cluster.GetName()
 			namespace := cluster.GetNamespace()
 
 			// Generate a unique cluster ID including the version
-			clusterID := fmt.Sprintf("capi-%s-%s-%s", capiGVR.Version, namespace, clusterName)
+			clusterID := capiClusterID(capiGVR.Version, namespace, clusterName)
 
 			// Check if cluster is already registered
 			if c.GetClusterById(clusterID) != nil {
@@ -305,26 +505,15 @@ func (c *ClusterInstances) RegisterClusterAPIConfigs() error {
 				continue
 			}
 
-			// Try to get the kubeconfig secret
-			secret, err := defaultCluster.Client.CoreV1().Secrets(namespace).Get(
-				context.Background(),
-				fmt.Sprintf("%s-kubeconfig", clusterName),
-				metav1.GetOptions{},
-			)
+			// Get kubeconfig data from the kubeconfig secret
+			kubeconfigData, err := capiKubeconfig(defaultCluster, namespace, clusterName)
 			if err != nil {
-				klog.Errorf("Failed to get kubeconfig secret for cluster %s in namespace %s: %v", clusterName, namespace, err)
-				continue
-			}
-
-			// Get kubeconfig data from secret
-			kubeconfigData, ok := secret.Data["value"]
-			if !ok {
-				klog.Errorf("Kubeconfig secret for cluster %s in namespace %s has no 'value' key", clusterName, namespace)
+				klog.Errorf("Failed to get kubeconfig for cluster %s in namespace %s: %v", clusterName, namespace, err)
 				continue
 			}
 
 			// Register the cluster
-			_, err = c.RegisterByStringWithID(string(kubeconfigData), clusterID)
+			_, err = c.RegisterByStringWithID(string(kubeconfigData), clusterID, capiRegisterOptions(cluster)...)
 			if err != nil {
 				klog.Errorf("Failed to register cluster %s in namespace %s: %v", clusterName, namespace, err)
 				continue
@@ -345,7 +534,12 @@ func (c *ClusterInstances) RegisterClusterAPIConfigs() error {
 // Show displays information about all clusters
 func (c *ClusterInstances) Show() {
 	klog.Infof("Show Clusters\n")
-	for k, v := range c.clusters {
+	for k, v := range c.AllClusters() {
+		if v.Options.Lazy && !v.initialized.Load() {
+			// Do not trigger loading just to print it
+			klog.Infof("%s[lazy]=%s\n", k, v.Config.Host)
+			continue
+		}
 		if v.serverVersion == nil {
 			klog.Infof("%s=nil\n", k)
 			continue
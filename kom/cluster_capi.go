@@ -0,0 +1,300 @@
+package kom
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// Supported CAPI versions, in order of preference
+var capiVersions = []schema.GroupVersionResource{
+	{
+		Group:    "cluster.x-k8s.io",
+		Version:  "v1beta1",
+		Resource: "clusters",
+	},
+	{
+		Group:    "cluster.x-k8s.io",
+		Version:  "v1alpha3",
+		Resource: "clusters",
+	},
+}
+
+const (
+	capiProvider           = "capi"
+	capiPhaseProvisioned   = "Provisioned"
+	capiPhaseDeleting      = "Deleting"
+	capiClusterNameLabel   = "cluster.x-k8s.io/cluster-name"
+	capiKubeconfigSuffix   = "-kubeconfig"
+	capiKubeconfigValueKey = "value"
+	capiResyncPeriod       = 10 * time.Minute
+)
+
+var secretsGVR = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+
+// capiClusterID generates a unique cluster ID including the version
+func capiClusterID(version, namespace, name string) string {
+	return fmt.Sprintf("capi-%s-%s-%s", version, namespace, name)
+}
+
+// capiKubeconfig reads the kubeconfig of a CAPI cluster from its <name>-kubeconfig secret
+func capiKubeconfig(hub *ClusterInst, namespace, name string) ([]byte, error) {
+	secret, err := hub.Client.CoreV1().Secrets(namespace).Get(hub.ctx, name+capiKubeconfigSuffix, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	data, ok := secret.Data[capiKubeconfigValueKey]
+	if !ok {
+		return nil, fmt.Errorf("kubeconfig secret %s/%s has no '%s' key", namespace, secret.Name, capiKubeconfigValueKey)
+	}
+	return data, nil
+}
+
+// capiSource builds the discovery information of a CAPI cluster object
+func capiSource(obj *unstructured.Unstructured) *ClusterSource {
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	return &ClusterSource{
+		Provider:  capiProvider,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Phase:     phase,
+		Labels:    obj.GetLabels(),
+	}
+}
+
+// capiRegisterOptions exposes the CAPI phase and labels on the registered cluster
+func capiRegisterOptions(obj *unstructured.Unstructured, opts ...RegisterOption) []RegisterOption {
+	return append([]RegisterOption{withSource(capiSource(obj)), WithLabels(obj.GetLabels())}, opts...)
+}
+
+// capiWatcher keeps CAPI clusters registered while watching the hub cluster
+type capiWatcher struct {
+	instances *ClusterInstances
+	hub       *ClusterInst
+	gvr       schema.GroupVersionResource
+	opts      []RegisterOption
+
+	mu     sync.Mutex
+	hashes map[string][32]byte // Kubeconfig hash per registered cluster ID
+}
+
+// WatchClusterAPIConfigs keeps Cluster API managed clusters registered until ctx is done
+// Clusters are registered once they reach the Provisioned phase and removed when they are deleted.
+// A rotated <name>-kubeconfig secret re-registers the cluster with the new kubeconfig.
+// The CAPI phase and labels are available through ClusterInst.Source().
+// Watching also stops when the default cluster, which acts as the hub, is closed.
+func (c *ClusterInstances) WatchClusterAPIConfigs(ctx context.Context, opts ...RegisterOption) error {
+	hub := c.DefaultCluster()
+	if hub == nil {
+		return fmt.Errorf("no default cluster available to discover CAPI clusters")
+	}
+
+	// Use the first CAPI version served by the hub
+	var gvr schema.GroupVersionResource
+	for _, v := range capiVersions {
+		if _, err := hub.DynamicClient.Resource(v).List(ctx, metav1.ListOptions{Limit: 1}); err != nil {
+			klog.V(4).Infof("CAPI version %s not available: %v", v.Version, err)
+			continue
+		}
+		gvr = v
+		break
+	}
+	if gvr.Empty() {
+		return fmt.Errorf("cluster API is not served by cluster %s", hub.ID)
+	}
+
+	w := &capiWatcher{
+		instances: c,
+		hub:       hub,
+		gvr:       gvr,
+		opts:      opts,
+		hashes:    map[string][32]byte{},
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	hub.OnClose(cancel)
+
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(hub.DynamicClient, capiResyncPeriod)
+	clusterInformer := factory.ForResource(gvr).Informer()
+	_, err := clusterInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { w.syncCluster(obj) },
+		UpdateFunc: func(_, obj interface{}) { w.syncCluster(obj) },
+		DeleteFunc: func(obj interface{}) { w.removeCluster(obj) },
+	})
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	// CAPI labels every kubeconfig secret with the cluster name
+	secretFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(hub.DynamicClient, capiResyncPeriod, metav1.NamespaceAll, func(o *metav1.ListOptions) {
+		o.LabelSelector = capiClusterNameLabel
+	})
+	secretInformer := secretFactory.ForResource(secretsGVR).Informer()
+	_, err = secretInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { w.syncSecret(obj) },
+		UpdateFunc: func(_, obj interface{}) { w.syncSecret(obj) },
+	})
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	factory.Start(ctx.Done())
+	secretFactory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), clusterInformer.HasSynced, secretInformer.HasSynced) {
+		cancel()
+		return fmt.Errorf("sync CAPI clusters from cluster %s error: %v", hub.ID, ctx.Err())
+	}
+	klog.V(4).Infof("watching CAPI %s clusters on cluster %s", gvr.Version, hub.ID)
+	return nil
+}
+
+// syncCluster registers a provisioned cluster and keeps its phase and labels up to date
+func (w *capiWatcher) syncCluster(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	source := capiSource(u)
+	id := capiClusterID(w.gvr.Version, u.GetNamespace(), u.GetName())
+
+	if u.GetDeletionTimestamp() != nil || source.Phase == capiPhaseDeleting {
+		w.unregister(id)
+		return
+	}
+	if cluster := w.instances.GetClusterById(id); cluster != nil {
+		cluster.setSource(source, newRegisterOptions(capiRegisterOptions(u, w.opts...)).Labels)
+		w.mu.Lock()
+		_, tracked := w.hashes[id]
+		w.mu.Unlock()
+		if !tracked {
+			// Registered before the watch started, remember its kubeconfig so a resync does not re-register it
+			w.adopt(id, u)
+		}
+		return
+	}
+	if source.Phase != capiPhaseProvisioned {
+		klog.V(6).Infof("CAPI cluster %s/%s is %s, waiting for %s", u.GetNamespace(), u.GetName(), source.Phase, capiPhaseProvisioned)
+		return
+	}
+	kubeconfig, err := capiKubeconfig(w.hub, u.GetNamespace(), u.GetName())
+	if err != nil {
+		klog.Errorf("Failed to get kubeconfig for CAPI cluster %s/%s: %v", u.GetNamespace(), u.GetName(), err)
+		return
+	}
+	w.register(id, u, kubeconfig)
+}
+
+// syncSecret registers a cluster whose kubeconfig secret arrived after it was provisioned,
+// and re-registers a cluster whose kubeconfig secret was rotated
+func (w *capiWatcher) syncSecret(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok || !strings.HasSuffix(u.GetName(), capiKubeconfigSuffix) {
+		return
+	}
+	name := strings.TrimSuffix(u.GetName(), capiKubeconfigSuffix)
+	id := capiClusterID(w.gvr.Version, u.GetNamespace(), name)
+
+	// Secret data is base64 encoded in unstructured form
+	value, _, _ := unstructured.NestedString(u.Object, "data", capiKubeconfigValueKey)
+	kubeconfig, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		klog.Errorf("Failed to decode kubeconfig secret %s/%s: %v", u.GetNamespace(), u.GetName(), err)
+		return
+	}
+	registered := w.instances.GetClusterById(id) != nil
+	w.mu.Lock()
+	hash, tracked := w.hashes[id]
+	if registered && !tracked {
+		// Registered before the watch started, this secret is its current kubeconfig
+		w.hashes[id] = sha256.Sum256(kubeconfig)
+	}
+	w.mu.Unlock()
+	if registered && (!tracked || hash == sha256.Sum256(kubeconfig)) {
+		return
+	}
+
+	capiCluster, err := w.hub.DynamicClient.Resource(w.gvr).Namespace(u.GetNamespace()).Get(w.hub.ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if !registered && apierrors.IsNotFound(err) {
+			// Not a CAPI cluster, or its Cluster object has not been created yet
+			return
+		}
+		klog.Errorf("Failed to get CAPI cluster %s/%s: %v", u.GetNamespace(), name, err)
+		return
+	}
+	if !registered {
+		source := capiSource(capiCluster)
+		if capiCluster.GetDeletionTimestamp() != nil || source.Phase != capiPhaseProvisioned {
+			return
+		}
+		w.register(id, capiCluster, kubeconfig)
+		return
+	}
+	klog.Infof("kubeconfig of CAPI cluster %s rotated, re-registering", id)
+	w.unregister(id)
+	w.register(id, capiCluster, kubeconfig)
+}
+
+// removeCluster unregisters a deleted cluster
+func (w *capiWatcher) removeCluster(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	w.unregister(capiClusterID(w.gvr.Version, u.GetNamespace(), u.GetName()))
+}
+
+func (w *capiWatcher) register(id string, obj *unstructured.Unstructured, kubeconfig []byte) {
+	_, err := w.instances.RegisterByStringWithID(string(kubeconfig), id, capiRegisterOptions(obj, w.opts...)...)
+	if err != nil {
+		klog.Errorf("Failed to register CAPI cluster %s: %v", id, err)
+		return
+	}
+	w.mu.Lock()
+	w.hashes[id] = sha256.Sum256(kubeconfig)
+	w.mu.Unlock()
+	klog.Infof("Successfully registered CAPI cluster %s/%s with ID %s", obj.GetNamespace(), obj.GetName(), id)
+}
+
+// adopt records the kubeconfig hash of a cluster that was already registered
+func (w *capiWatcher) adopt(id string, obj *unstructured.Unstructured) {
+	kubeconfig, err := capiKubeconfig(w.hub, obj.GetNamespace(), obj.GetName())
+	if err != nil {
+		klog.Errorf("Failed to get kubeconfig for CAPI cluster %s/%s: %v", obj.GetNamespace(), obj.GetName(), err)
+		return
+	}
+	w.mu.Lock()
+	w.hashes[id] = sha256.Sum256(kubeconfig)
+	w.mu.Unlock()
+}
+
+func (w *capiWatcher) unregister(id string) {
+	w.mu.Lock()
+	delete(w.hashes, id)
+	w.mu.Unlock()
+	if w.instances.GetClusterById(id) == nil {
+		return
+	}
+	if err := w.instances.RemoveClusterById(id); err != nil {
+		klog.Errorf("Failed to unregister CAPI cluster %s: %v", id, err)
+		return
+	}
+	klog.Infof("Unregistered CAPI cluster %s", id)
+}
@@ -0,0 +1,202 @@
+package kom
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// defaultFleetConcurrency is the number of clusters ForEach works on at the same time
+const defaultFleetConcurrency = 10
+
+// ClusterSet is a group of registered clusters, usually selected by labels
+type ClusterSet struct {
+	clusters    []*ClusterInst
+	concurrency int
+	Error       error // Selector parse error
+}
+
+// ClusterResult is the outcome of a fleet operation on a single cluster
+type ClusterResult struct {
+	ID       string        `json:"id"`
+	Value    any           `json:"value,omitempty"` // Returned by the function of ForEachValue
+	Error    error         `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// FleetResult aggregates the per-cluster outcomes of a fleet operation
+type FleetResult struct {
+	Results []*ClusterResult `json:"results"` // Ordered by cluster ID
+}
+
+// Labels returns a copy of the labels the cluster was registered with
+func (c *ClusterInst) Labels() map[string]string {
+	c.sourceMu.RLock()
+	defer c.sourceMu.RUnlock()
+	cp := make(map[string]string, len(c.Options.Labels))
+	for k, v := range c.Options.Labels {
+		cp[k] = v
+	}
+	return cp
+}
+
+// Select returns the clusters whose labels match the selector, e.g. "env=prod,region in (eu,us)"
+// An empty selector selects every cluster.
+func (c *ClusterInstances) Select(selector string) *ClusterSet {
+	set := &ClusterSet{concurrency: defaultFleetConcurrency}
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		set.Error = fmt.Errorf("invalid cluster selector %q: %v", selector, err)
+		return set
+	}
+	for _, cluster := range c.AllClusters() {
+		if sel.Matches(labels.Set(cluster.Labels())) {
+			set.clusters = append(set.clusters, cluster)
+		}
+	}
+	sort.Slice(set.clusters, func(i, j int) bool {
+		return set.clusters[i].ID < set.clusters[j].ID
+	})
+	return set
+}
+
+// ForEach runs fn on every registered cluster in parallel
+func (c *ClusterInstances) ForEach(fn func(*Kubectl) error) *FleetResult {
+	return c.Select("").ForEach(fn)
+}
+
+// ForEachValue runs fn on every registered cluster in parallel, keeping the value it returns
+func (c *ClusterInstances) ForEachValue(fn func(*Kubectl) (any, error)) *FleetResult {
+	return c.Select("").ForEachValue(fn)
+}
+
+// Concurrency limits how many clusters ForEach works on at the same time
+func (s *ClusterSet) Concurrency(n int) *ClusterSet {
+	if n > 0 {
+		s.concurrency = n
+	}
+	return s
+}
+
+// IDs returns the IDs of the selected clusters
+func (s *ClusterSet) IDs() []string {
+	ids := make([]string, 0, len(s.clusters))
+	for _, cluster := range s.clusters {
+		ids = append(ids, cluster.ID)
+	}
+	return ids
+}
+
+// Clusters returns the selected clusters
+func (s *ClusterSet) Clusters() []*ClusterInst {
+	return s.clusters
+}
+
+// ForEach runs fn on every selected cluster with bounded concurrency
+// A failure or panic on one cluster does not stop the others, every outcome is in the result.
+//
+// Example:
+//
+//	result := kom.Clusters().Select("env=prod").ForEach(func(k *kom.Kubectl) error {
+//		return k.Resource(&v1.Deployment{}).Namespace("app").Name("web").Ctl().Scale(0)
+//	})
+//	if err := result.Err(); err != nil {
+//		...
+//	}
+func (s *ClusterSet) ForEach(fn func(*Kubectl) error) *FleetResult {
+	return s.ForEachValue(func(k *Kubectl) (any, error) {
+		return nil, fn(k)
+	})
+}
+
+// ForEachValue runs fn on every selected cluster like ForEach and keeps the value it returns in ClusterResult.Value
+//
+// Example:
+//
+//	result := kom.Clusters().Select("env=prod").ForEachValue(func(k *kom.Kubectl) (any, error) {
+//		return k.Status().ServerVersion(), nil
+//	})
+//	for id, v := range result.Values() {
+//		fmt.Println(id, v.(*version.Info).GitVersion)
+//	}
+func (s *ClusterSet) ForEachValue(fn func(*Kubectl) (any, error)) *FleetResult {
+	result := &FleetResult{Results: make([]*ClusterResult, len(s.clusters))}
+	if s.Error != nil {
+		result.Results = []*ClusterResult{{Error: s.Error}}
+		return result
+	}
+
+	concurrency := s.concurrency
+	if concurrency <= 0 {
+		concurrency = defaultFleetConcurrency
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, cluster := range s.clusters {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, cluster *ClusterInst) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			start := time.Now()
+			value, err := runOnCluster(cluster, fn)
+			result.Results[i] = &ClusterResult{
+				ID:       cluster.ID,
+				Value:    value,
+				Error:    err,
+				Duration: time.Since(start),
+			}
+		}(i, cluster)
+	}
+	wg.Wait()
+	return result
+}
+
+// runOnCluster runs fn on a single cluster, turning a panic into an error
+func runOnCluster(cluster *ClusterInst, fn func(*Kubectl) (any, error)) (value any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(cluster.Kubectl)
+}
+
+// Values returns the values of the clusters that succeeded, by cluster ID
+func (r *FleetResult) Values() map[string]any {
+	values := map[string]any{}
+	for _, res := range r.Results {
+		if res.Error == nil && res.ID != "" {
+			values[res.ID] = res.Value
+		}
+	}
+	return values
+}
+
+// Failed returns the results of the clusters that returned an error
+func (r *FleetResult) Failed() []*ClusterResult {
+	var failed []*ClusterResult
+	for _, res := range r.Results {
+		if res.Error != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Err returns all per-cluster errors joined together, nil if every cluster succeeded
+func (r *FleetResult) Err() error {
+	var errs []error
+	for _, res := range r.Failed() {
+		if res.ID == "" {
+			errs = append(errs, res.Error)
+			continue
+		}
+		errs = append(errs, fmt.Errorf("cluster %s: %w", res.ID, res.Error))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,214 @@
+package kom
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+// Context returns the cluster-scoped context
+// It is cancelled when the cluster is closed, and every operation on the cluster is bound to it
+func (c *ClusterInst) Context() context.Context {
+	return c.ctx
+}
+
+// Closed reports whether the cluster has been closed
+func (c *ClusterInst) Closed() bool {
+	return c.closed.Load()
+}
+
+// OnClose registers a function that runs when the cluster is closed
+// Use it to stop watches, refreshers or other background work tied to the cluster.
+// If the cluster is already closed, fn runs immediately.
+func (c *ClusterInst) OnClose(fn func()) {
+	if fn == nil {
+		return
+	}
+	c.closeMu.Lock()
+	if c.closed.Load() {
+		c.closeMu.Unlock()
+		fn()
+		return
+	}
+	c.closers = append(c.closers, fn)
+	c.closeMu.Unlock()
+}
+
+// Close releases everything held by the cluster
+// Registered OnClose functions run in reverse order, then the cluster context is cancelled,
+// which ends running watches and log streams, and finally the cache is closed.
+// Closing an already closed cluster is a no-op.
+func (c *ClusterInst) Close() error {
+	c.closeMu.Lock()
+	if c.closed.Load() {
+		c.closeMu.Unlock()
+		return nil
+	}
+	c.closed.Store(true)
+	closers := c.closers
+	c.closers = nil
+	c.closeMu.Unlock()
+
+	klog.V(4).Infof("closing cluster %s", c.ID)
+	for i := len(closers) - 1; i >= 0; i-- {
+		runCloser(c.ID, closers[i])
+	}
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	if c.Cache != nil {
+		c.Cache.Close()
+	}
+
+	c.impersonatedMu.Lock()
+	c.impersonatedClients = nil
+	c.impersonatedLRU = nil
+	c.impersonatedMu.Unlock()
+	return nil
+}
+
+// runCloser runs a single close function, a panic in one must not stop the others
+func runCloser(id string, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			klog.Errorf("cluster %s close function panic: %v", id, r)
+		}
+	}()
+	fn()
+}
+
+// trackPod deletes a helper pod created by kom when the cluster is closed, e.g. node shells
+func (c *ClusterInst) trackPod(ns, name string) {
+	c.OnClose(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err := c.Client.CoreV1().Pods(ns).Delete(ctx, name, metav1.DeleteOptions{})
+		if err != nil {
+			klog.V(4).Infof("cluster %s delete helper pod %s/%s error: %v", c.ID, ns, name, err)
+		}
+	})
+}
+
+// withClusterContext derives a context that ends when either ctx or the cluster context ends
+func (k *Kubectl) withClusterContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cluster := k.parentCluster()
+	if cluster == nil || cluster.ctx == nil || ctx == cluster.ctx {
+		return context.WithCancel(ctx)
+	}
+	merged, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(cluster.ctx, cancel)
+	return merged, func() {
+		stop()
+		cancel()
+	}
+}
+
+// releaseWhenStreamEnds calls release once the stream handed back in Dest ends
+// Watches end on Stop or when the server closes them, log streams on Close or a read error, port forwards when Done fires.
+func (k *Kubectl) releaseWhenStreamEnds(release context.CancelFunc) {
+	switch dest := k.Statement.Dest.(type) {
+	case *watch.Interface:
+		if *dest != nil {
+			*dest = newReleasingWatch(*dest, release)
+			return
+		}
+	case *io.ReadCloser:
+		if *dest != nil {
+			*dest = &releasingReadCloser{ReadCloser: *dest, release: release}
+			return
+		}
+	}
+	if req := k.Statement.PortForward; req != nil {
+		req.release = release
+		return
+	}
+	klog.V(6).Infof("cluster %s stream of type %T is released when the cluster closes", k.ID, k.Statement.Dest)
+}
+
+// releasingWatch forwards the events of a watch and releases its context when it ends
+type releasingWatch struct {
+	watch.Interface
+	result  chan watch.Event
+	stopped chan struct{}
+	once    sync.Once
+	release context.CancelFunc
+}
+
+func newReleasingWatch(w watch.Interface, release context.CancelFunc) *releasingWatch {
+	rw := &releasingWatch{Interface: w, result: make(chan watch.Event), stopped: make(chan struct{}), release: release}
+	go rw.forward()
+	return rw
+}
+
+func (w *releasingWatch) forward() {
+	defer close(w.result)
+	defer w.release()
+	for ev := range w.Interface.ResultChan() {
+		select {
+		case w.result <- ev:
+		case <-w.stopped:
+			return
+		}
+	}
+}
+
+func (w *releasingWatch) ResultChan() <-chan watch.Event {
+	return w.result
+}
+
+func (w *releasingWatch) Stop() {
+	w.once.Do(func() {
+		close(w.stopped)
+		w.Interface.Stop()
+	})
+}
+
+// releasingReadCloser releases the context of a stream once it is closed or fails
+type releasingReadCloser struct {
+	io.ReadCloser
+	release context.CancelFunc
+}
+
+func (r *releasingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err != nil {
+		r.release()
+	}
+	return n, err
+}
+
+func (r *releasingReadCloser) Close() error {
+	defer r.release()
+	return r.ReadCloser.Close()
+}
+
+// unavailableConfig returns a config whose requests fail because the cluster is gone
+func unavailableConfig(id string) *rest.Config {
+	return &rest.Config{Host: "https://cluster-unavailable.invalid", Transport: unavailableTransport{err: errClusterUnavailable(id)}}
+}
+
+type unavailableTransport struct {
+	err error
+}
+
+func (t unavailableTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
+
+// errClusterUnavailable is returned by operations on a cluster that was removed or closed
+func errClusterUnavailable(id string) error {
+	return fmt.Errorf("cluster %s is not registered or has been closed", id)
+}
@@ -0,0 +1,61 @@
+package kom
+
+// RegisterOptions controls how a cluster is initialized when it is registered
+type RegisterOptions struct {
+	// Lazy defers fetching API resources, the CRD list, the server version,
+	// the OpenAPI document, doc trees and describers until they are first needed
+	Lazy bool `json:"lazy,omitempty"`
+	// Labels are arbitrary key/value pairs attached to the cluster, e.g. env=prod
+	Labels map[string]string `json:"labels,omitempty"`
+
+	kubeconfig  string         // Kubeconfig content, set when registering from a string so it can be persisted
+	skipPersist bool           // Set when restoring from the store, the record is already there
+	source      *ClusterSource // Set for clusters registered by a discovery provider
+}
+
+// RegisterOption configures RegisterOptions
+type RegisterOption func(*RegisterOptions)
+
+// WithLazyInit registers the cluster without loading discovery data up front
+// Each piece is loaded on first use, e.g. by Sql, Describe, Status().Docs() or a GVK lookup
+func WithLazyInit() RegisterOption {
+	return func(o *RegisterOptions) {
+		o.Lazy = true
+	}
+}
+
+// WithLabels attaches labels to the cluster
+func WithLabels(labels map[string]string) RegisterOption {
+	return func(o *RegisterOptions) {
+		if o.Labels == nil {
+			o.Labels = make(map[string]string, len(labels))
+		}
+		for k, v := range labels {
+			o.Labels[k] = v
+		}
+	}
+}
+
+// withRegisterOptions replaces all options, used when restoring persisted clusters
+func withRegisterOptions(options RegisterOptions) RegisterOption {
+	return func(o *RegisterOptions) {
+		*o = options
+	}
+}
+
+// withKubeconfig keeps the kubeconfig content so the registration can be persisted
+func withKubeconfig(str string) RegisterOption {
+	return func(o *RegisterOptions) {
+		o.kubeconfig = str
+	}
+}
+
+func newRegisterOptions(opts []RegisterOption) RegisterOptions {
+	options := RegisterOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	return options
+}
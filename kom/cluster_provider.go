@@ -0,0 +1,166 @@
+package kom
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+// DiscoveredCluster is a cluster found by a ClusterProvider
+type DiscoveredCluster struct {
+	ID     string         // Cluster ID to register with
+	Config *rest.Config   // Connection config of the cluster, nil when the cluster is known but cannot be connected to right now
+	Source *ClusterSource // Inventory object the cluster was found from
+}
+
+// ClusterProvider discovers clusters from an inventory, such as a hub cluster
+// Implement it to plug in a custom inventory source.
+type ClusterProvider interface {
+	// Name identifies the provider, it is recorded as ClusterSource.Provider
+	Name() string
+	// Discover returns every cluster currently known to the inventory
+	// Clusters that are skipped for now are returned without Config, so a sync keeps them instead of pruning them.
+	Discover(ctx context.Context) ([]*DiscoveredCluster, error)
+}
+
+// RegisterFromProvider registers every cluster discovered by the provider once
+// Clusters that are already registered keep their connection, only their source is refreshed.
+func (c *ClusterInstances) RegisterFromProvider(ctx context.Context, provider ClusterProvider, opts ...RegisterOption) error {
+	s := &providerSyncer{instances: c, provider: provider, opts: opts, fingerprints: map[string][32]byte{}}
+	return s.sync(ctx, false)
+}
+
+// SyncProvider keeps the clusters of a provider registered until ctx is done
+// Every interval, new clusters are registered, clusters with changed credentials are re-registered
+// and clusters that disappeared from the inventory are unregistered.
+// The first sync runs before SyncProvider returns. When it fails, its error is returned and nothing keeps syncing,
+// clusters it did register stay registered. Otherwise the syncing runs in the background until ctx is cancelled.
+func (c *ClusterInstances) SyncProvider(ctx context.Context, provider ClusterProvider, interval time.Duration, opts ...RegisterOption) error {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	s := &providerSyncer{instances: c, provider: provider, opts: opts, fingerprints: map[string][32]byte{}}
+	if err := s.sync(ctx, true); err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				klog.V(4).Infof("stop syncing clusters from provider %s: %v", provider.Name(), ctx.Err())
+				return
+			case <-ticker.C:
+				if err := s.sync(ctx, true); err != nil {
+					klog.Errorf("sync clusters from provider %s error: %v", provider.Name(), err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// providerSyncer registers the clusters of a single provider
+type providerSyncer struct {
+	instances    *ClusterInstances
+	provider     ClusterProvider
+	opts         []RegisterOption
+	fingerprints map[string][32]byte // Connection fingerprint per registered cluster ID
+}
+
+func (s *providerSyncer) sync(ctx context.Context, prune bool) error {
+	discovered, err := s.provider.Discover(ctx)
+	if err != nil {
+		return fmt.Errorf("discover clusters from provider %s error: %v", s.provider.Name(), err)
+	}
+
+	var errs []error
+	seen := map[string]bool{}
+	for _, dc := range discovered {
+		if dc == nil || dc.ID == "" {
+			continue
+		}
+		seen[dc.ID] = true
+		// Copied, the provider may hand back the same source on every sync
+		source := &ClusterSource{Name: dc.ID}
+		if dc.Source != nil {
+			cp := *dc.Source
+			source = &cp
+		}
+		source.Provider = s.provider.Name()
+		opts := append([]RegisterOption{withSource(source), WithLabels(source.Labels)}, s.opts...)
+
+		if dc.Config == nil {
+			// Skipped for now, e.g. unavailable or its credentials could not be read, a registered cluster is kept
+			if cluster := s.instances.GetClusterById(dc.ID); cluster != nil {
+				cluster.setSource(source, newRegisterOptions(opts).Labels)
+			}
+			continue
+		}
+
+		fingerprint := configFingerprint(dc.Config)
+		if cluster := s.instances.GetClusterById(dc.ID); cluster != nil {
+			known, tracked := s.fingerprints[dc.ID]
+			if !tracked || known == fingerprint {
+				s.fingerprints[dc.ID] = fingerprint
+				cluster.setSource(source, newRegisterOptions(opts).Labels)
+				continue
+			}
+			// Credentials or endpoint changed, reconnect
+			klog.Infof("connection of cluster %s from provider %s changed, re-registering", dc.ID, s.provider.Name())
+			if err := s.instances.RemoveClusterById(dc.ID); err != nil {
+				klog.Errorf("unregister cluster %s error: %v", dc.ID, err)
+			}
+		}
+
+		if _, err := s.instances.RegisterByConfigWithID(dc.Config, dc.ID, opts...); err != nil {
+			errs = append(errs, fmt.Errorf("cluster %s: %v", dc.ID, err))
+			continue
+		}
+		s.fingerprints[dc.ID] = fingerprint
+		klog.Infof("Successfully registered cluster %s from provider %s", dc.ID, s.provider.Name())
+	}
+
+	if prune {
+		for id, cluster := range s.instances.AllClusters() {
+			source := cluster.Source()
+			if source == nil || source.Provider != s.provider.Name() || seen[id] {
+				continue
+			}
+			delete(s.fingerprints, id)
+			if err := s.instances.RemoveClusterById(id); err != nil {
+				errs = append(errs, fmt.Errorf("cluster %s: %v", id, err))
+				continue
+			}
+			klog.Infof("Unregistered cluster %s, it is no longer known to provider %s", id, s.provider.Name())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// configFingerprint detects endpoint or credential changes of a discovered cluster
+func configFingerprint(config *rest.Config) [32]byte {
+	h := sha256.New()
+	for _, part := range []string{
+		config.Host,
+		config.BearerToken,
+		config.Username,
+		config.Password,
+		string(config.TLSClientConfig.CAData),
+		string(config.TLSClientConfig.CertData),
+		string(config.TLSClientConfig.KeyData),
+		fmt.Sprint(config.TLSClientConfig.Insecure),
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
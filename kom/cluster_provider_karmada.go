@@ -0,0 +1,100 @@
+package kom
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+var karmadaClusterGVR = schema.GroupVersionResource{
+	Group:    "cluster.karmada.io",
+	Version:  "v1alpha1",
+	Resource: "clusters",
+}
+
+// karmadaProvider discovers Karmada member clusters on the Karmada control plane
+type karmadaProvider struct {
+	hub *Kubectl
+}
+
+// NewKarmadaProvider creates a provider that reads cluster.karmada.io Cluster objects
+// hub must be registered against the Karmada API server.
+// Only Push mode clusters are discovered, they reference a secret holding "token" and "caBundle".
+func NewKarmadaProvider(hub *Kubectl) ClusterProvider {
+	return &karmadaProvider{hub: hub}
+}
+
+func (p *karmadaProvider) Name() string {
+	return "karmada"
+}
+
+func (p *karmadaProvider) Discover(ctx context.Context) ([]*DiscoveredCluster, error) {
+	if p.hub == nil {
+		return nil, fmt.Errorf("karmada control plane is not registered")
+	}
+	list, err := p.hub.DynamicClient().Resource(karmadaClusterGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var clusters []*DiscoveredCluster
+	for i := range list.Items {
+		item := &list.Items[i]
+		name := item.GetName()
+		source := &ClusterSource{
+			Name:   name,
+			Phase:  conditionPhase(item, "Ready", "Ready", "NotReady"),
+			Labels: item.GetLabels(),
+		}
+
+		// Skipped clusters are still returned without config, so they are not pruned
+		dc := &DiscoveredCluster{ID: fmt.Sprintf("karmada-%s", name), Source: source}
+
+		endpoint, _, _ := unstructured.NestedString(item.Object, "spec", "apiEndpoint")
+		secretNs, _, _ := unstructured.NestedString(item.Object, "spec", "secretRef", "namespace")
+		secretName, _, _ := unstructured.NestedString(item.Object, "spec", "secretRef", "name")
+		if endpoint == "" || secretName == "" {
+			klog.V(4).Infof("Karmada cluster %s has no endpoint or secretRef (Pull mode?), skipped", name)
+			clusters = append(clusters, dc)
+			continue
+		}
+		insecure, _, _ := unstructured.NestedBool(item.Object, "spec", "insecureSkipTLSVerification")
+		proxyURL, _, _ := unstructured.NestedString(item.Object, "spec", "proxyURL")
+
+		secret, err := p.hub.Client().CoreV1().Secrets(secretNs).Get(ctx, secretName, metav1.GetOptions{})
+		if err != nil {
+			klog.Errorf("Failed to get secret %s/%s for Karmada cluster %s: %v", secretNs, secretName, name, err)
+			clusters = append(clusters, dc)
+			continue
+		}
+		config := &rest.Config{
+			Host:        endpoint,
+			BearerToken: string(secret.Data["token"]),
+		}
+		if insecure {
+			config.TLSClientConfig.Insecure = true
+		} else {
+			config.TLSClientConfig.CAData = secret.Data["caBundle"]
+		}
+		if proxyURL != "" {
+			proxy, err := url.Parse(proxyURL)
+			if err != nil {
+				klog.Errorf("Invalid proxyURL %s of Karmada cluster %s: %v", proxyURL, name, err)
+				clusters = append(clusters, dc)
+				continue
+			}
+			config.Proxy = http.ProxyURL(proxy)
+		}
+
+		dc.Config = config
+		clusters = append(clusters, dc)
+	}
+	return clusters, nil
+}
@@ -0,0 +1,115 @@
+package kom
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+var ocmManagedClusterGVR = schema.GroupVersionResource{
+	Group:    "cluster.open-cluster-management.io",
+	Version:  "v1",
+	Resource: "managedclusters",
+}
+
+// ocmProvider discovers Open Cluster Management ManagedClusters on a hub cluster
+type ocmProvider struct {
+	hub            *Kubectl
+	serviceAccount string
+}
+
+// NewOCMProvider creates a provider that reads ManagedCluster objects on the hub
+// Access to each managed cluster comes from the token secret of a ManagedServiceAccount
+// named serviceAccount, which the managed-serviceaccount addon writes into the cluster namespace on the hub.
+// The endpoint is taken from spec.managedClusterClientConfigs.
+func NewOCMProvider(hub *Kubectl, serviceAccount string) ClusterProvider {
+	return &ocmProvider{hub: hub, serviceAccount: serviceAccount}
+}
+
+func (p *ocmProvider) Name() string {
+	return "ocm"
+}
+
+func (p *ocmProvider) Discover(ctx context.Context) ([]*DiscoveredCluster, error) {
+	if p.hub == nil {
+		return nil, fmt.Errorf("ocm hub cluster is not registered")
+	}
+	list, err := p.hub.DynamicClient().Resource(ocmManagedClusterGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var clusters []*DiscoveredCluster
+	for i := range list.Items {
+		mc := &list.Items[i]
+		name := mc.GetName()
+		source := &ClusterSource{
+			Name:   name,
+			Phase:  conditionPhase(mc, "ManagedClusterConditionAvailable", "Available", "Unavailable"),
+			Labels: mc.GetLabels(),
+		}
+		// Skipped clusters are still returned without config, so they are not pruned
+		dc := &DiscoveredCluster{ID: fmt.Sprintf("ocm-%s", name), Source: source}
+		if source.Phase != "Available" {
+			klog.V(6).Infof("OCM managed cluster %s is %s, skipped", name, source.Phase)
+			clusters = append(clusters, dc)
+			continue
+		}
+
+		configs, _, _ := unstructured.NestedSlice(mc.Object, "spec", "managedClusterClientConfigs")
+		if len(configs) == 0 {
+			klog.V(4).Infof("OCM managed cluster %s has no client config, skipped", name)
+			clusters = append(clusters, dc)
+			continue
+		}
+		clientConfig, _ := configs[0].(map[string]interface{})
+		url, _, _ := unstructured.NestedString(clientConfig, "url")
+		caBundle, _, _ := unstructured.NestedString(clientConfig, "caBundle")
+
+		// The managed service account token lives in the cluster namespace on the hub
+		secret, err := p.hub.Client().CoreV1().Secrets(name).Get(ctx, p.serviceAccount, metav1.GetOptions{})
+		if err != nil {
+			klog.Errorf("Failed to get token secret %s/%s for OCM managed cluster %s: %v", name, p.serviceAccount, name, err)
+			clusters = append(clusters, dc)
+			continue
+		}
+		config := &rest.Config{
+			Host:        url,
+			BearerToken: string(secret.Data["token"]),
+		}
+		config.TLSClientConfig.CAData = secret.Data["ca.crt"]
+		if len(config.TLSClientConfig.CAData) == 0 && caBundle != "" {
+			if ca, err := base64.StdEncoding.DecodeString(caBundle); err == nil {
+				config.TLSClientConfig.CAData = ca
+			}
+		}
+
+		dc.Config = config
+		clusters = append(clusters, dc)
+	}
+	return clusters, nil
+}
+
+// conditionPhase maps the status of a condition to a phase name
+func conditionPhase(obj *unstructured.Unstructured, conditionType, truePhase, falsePhase string) string {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != conditionType {
+			continue
+		}
+		switch condition["status"] {
+		case string(metav1.ConditionTrue):
+			return truePhase
+		case string(metav1.ConditionFalse):
+			return falsePhase
+		}
+	}
+	return "Unknown"
+}
@@ -0,0 +1,36 @@
+package kom
+
+// ClusterSource describes the inventory object a discovered cluster was registered from
+type ClusterSource struct {
+	Provider  string            `json:"provider"`            // Discovery provider, e.g. capi
+	Namespace string            `json:"namespace,omitempty"` // Namespace of the inventory object on the hub cluster
+	Name      string            `json:"name"`                // Name of the inventory object on the hub cluster
+	Phase     string            `json:"phase,omitempty"`     // Lifecycle phase reported by the provider, e.g. Provisioned
+	Labels    map[string]string `json:"labels,omitempty"`    // Labels of the inventory object
+}
+
+// Source returns where the cluster was discovered from, nil for clusters registered directly
+func (c *ClusterInst) Source() *ClusterSource {
+	c.sourceMu.RLock()
+	defer c.sourceMu.RUnlock()
+	if c.source == nil {
+		return nil
+	}
+	cp := *c.source
+	return &cp
+}
+
+// setSource updates the discovery information and the labels, e.g. when the phase or labels change
+func (c *ClusterInst) setSource(source *ClusterSource, labels map[string]string) {
+	c.sourceMu.Lock()
+	defer c.sourceMu.Unlock()
+	c.source = source
+	c.Options.Labels = labels
+}
+
+// withSource records where a discovered cluster came from
+func withSource(source *ClusterSource) RegisterOption {
+	return func(o *RegisterOptions) {
+		o.source = source
+	}
+}
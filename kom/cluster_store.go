@@ -0,0 +1,325 @@
+package kom
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+// ClusterRecord is a persisted cluster registration
+type ClusterRecord struct {
+	ID         string          `json:"id"`         // Cluster ID
+	Kubeconfig string          `json:"kubeconfig"` // Kubeconfig content
+	Options    RegisterOptions `json:"options"`    // Registration options, including labels
+}
+
+// ClusterStore persists registered clusters so they can be restored on startup
+// Implementations must be safe for concurrent use
+type ClusterStore interface {
+	// Save creates or replaces the record with the same ID
+	Save(record *ClusterRecord) error
+	// Delete removes the record, deleting an unknown ID is not an error
+	Delete(id string) error
+	// List returns all records
+	List() ([]*ClusterRecord, error)
+}
+
+// SetStore sets the store used to persist clusters
+// Clusters registered from a kubeconfig string are saved, and clusters removed by RemoveClusterById are deleted from it.
+// Clusters registered from a rest.Config, a kubeconfig path or a discovery source such as CAPI are not persisted.
+func (c *ClusterInstances) SetStore(store ClusterStore) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store = store
+}
+
+// Store returns the store used to persist clusters, nil if none is set
+func (c *ClusterInstances) Store() ClusterStore {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.store
+}
+
+// LoadFromStore registers every cluster saved in the store
+// Clusters that fail to register are skipped, their errors are returned together
+func (c *ClusterInstances) LoadFromStore() error {
+	store := c.Store()
+	if store == nil {
+		return fmt.Errorf("cluster store is not set")
+	}
+	records, err := store.List()
+	if err != nil {
+		return fmt.Errorf("load clusters from store error: %v", err)
+	}
+	var errs []error
+	for _, record := range records {
+		opts := []RegisterOption{withRegisterOptions(record.Options), func(o *RegisterOptions) {
+			o.skipPersist = true
+		}}
+		if _, err := c.RegisterByStringWithID(record.Kubeconfig, record.ID, opts...); err != nil {
+			klog.Errorf("restore cluster %s from store error: %v", record.ID, err)
+			errs = append(errs, fmt.Errorf("cluster %s: %v", record.ID, err))
+			continue
+		}
+		klog.V(4).Infof("restored cluster %s from store", record.ID)
+	}
+	return errors.Join(errs...)
+}
+
+// persist saves a newly registered cluster to the store, if one is set
+// Clusters from a discovery source are skipped, their source registers them again and prunes them when they go away.
+func (c *ClusterInstances) persist(cluster *ClusterInst) {
+	store := c.Store()
+	options := cluster.Options
+	if store == nil || options.kubeconfig == "" || options.skipPersist || options.source != nil {
+		return
+	}
+	err := store.Save(&ClusterRecord{
+		ID:         cluster.ID,
+		Kubeconfig: options.kubeconfig,
+		Options:    options,
+	})
+	if err != nil {
+		klog.Errorf("save cluster %s to store error: %v", cluster.ID, err)
+	}
+}
+
+// fileClusterStore stores all records in a single JSON file
+type fileClusterStore struct {
+	path       string
+	passphrase []byte // Empty means plaintext
+	salt       []byte // Salt of key, read from the file or generated on first save
+	key        []byte // AES-256 key derived from passphrase and salt
+	mu         sync.Mutex
+}
+
+const (
+	// encryptedPrefix marks an encrypted store file, followed by base64(salt + nonce + ciphertext)
+	encryptedPrefix = "kom-enc:v2:"
+	// storeSaltSize is the size of the random salt used to derive the key
+	storeSaltSize = 16
+	// storeKeyIterations is the PBKDF2-HMAC-SHA256 iteration count
+	storeKeyIterations = 600000
+)
+
+// NewFileClusterStore creates a store backed by the file at path
+// When passphrase is not empty, the file is encrypted at rest with AES-256-GCM.
+// The key is derived from the passphrase with PBKDF2-HMAC-SHA256 and a random salt stored in the file.
+// The file is created with 0600 permissions on first save.
+func NewFileClusterStore(path string, passphrase []byte) ClusterStore {
+	s := &fileClusterStore{path: path}
+	if len(passphrase) > 0 {
+		s.passphrase = append([]byte(nil), passphrase...)
+	}
+	return s
+}
+
+func (s *fileClusterStore) Save(record *ClusterRecord) error {
+	if record == nil || record.ID == "" {
+		return fmt.Errorf("cluster record must have an ID")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records, err := s.read()
+	if err != nil {
+		return err
+	}
+	records[record.ID] = record
+	return s.write(records)
+}
+
+func (s *fileClusterStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := records[id]; !ok {
+		return nil
+	}
+	delete(records, id)
+	return s.write(records)
+}
+
+func (s *fileClusterStore) List() ([]*ClusterRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*ClusterRecord, 0, len(records))
+	for _, record := range records {
+		list = append(list, record)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+func (s *fileClusterStore) read() (map[string]*ClusterRecord, error) {
+	records := make(map[string]*ClusterRecord)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read cluster store %s error: %v", s.path, err)
+	}
+	if len(data) == 0 {
+		return records, nil
+	}
+
+	encrypted := strings.HasPrefix(string(data), encryptedPrefix)
+	if encrypted != (s.passphrase != nil) {
+		return nil, fmt.Errorf("cluster store %s encryption does not match the configured passphrase", s.path)
+	}
+	if encrypted {
+		if data, err = s.decrypt(data); err != nil {
+			return nil, fmt.Errorf("decrypt cluster store %s error: %v", s.path, err)
+		}
+	}
+	if err = json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("parse cluster store %s error: %v", s.path, err)
+	}
+	return records, nil
+}
+
+func (s *fileClusterStore) write(records map[string]*ClusterRecord) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if s.passphrase != nil {
+		if data, err = s.encrypt(data); err != nil {
+			return fmt.Errorf("encrypt cluster store %s error: %v", s.path, err)
+		}
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated store
+	dir := filepath.Dir(s.path)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".kom-clusters-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err = tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *fileClusterStore) encrypt(plain []byte) ([]byte, error) {
+	if s.key == nil {
+		// First save of a new store, later saves keep the salt so the key is derived once
+		salt := make([]byte, storeSaltSize)
+		if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+			return nil, err
+		}
+		s.deriveKey(salt)
+	}
+	gcm, err := s.gcm()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	sealed := gcm.Seal(append(append([]byte(nil), s.salt...), nonce...), nonce, plain, nil)
+	return []byte(encryptedPrefix + base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+func (s *fileClusterStore) decrypt(data []byte) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(strings.TrimPrefix(string(data), encryptedPrefix)))
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < storeSaltSize {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	salt, sealed := sealed[:storeSaltSize], sealed[storeSaltSize:]
+	if s.key == nil || !bytes.Equal(salt, s.salt) {
+		s.deriveKey(salt)
+	}
+	gcm, err := s.gcm()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+// deriveKey derives the AES-256 key from the passphrase and salt
+func (s *fileClusterStore) deriveKey(salt []byte) {
+	s.salt = append([]byte(nil), salt...)
+	s.key = pbkdf2SHA256(s.passphrase, s.salt, storeKeyIterations, 32)
+}
+
+func (s *fileClusterStore) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 implements PBKDF2 (RFC 8018) with HMAC-SHA256
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	size := prf.Size()
+	blocks := (keyLen + size - 1) / size
+	key := make([]byte, 0, blocks*size)
+	var counter [4]byte
+	u := make([]byte, size)
+	for block := 1; block <= blocks; block++ {
+		binary.BigEndian.PutUint32(counter[:], uint32(block))
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(counter[:])
+		u = prf.Sum(u[:0])
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
@@ -34,6 +34,11 @@ func (c *ctl) Pod() *pod {
 		kubectl: c.kubectl,
 	}
 }
+func (c *ctl) Service() *service {
+	return &service{
+		kubectl: c.kubectl,
+	}
+}
 func (c *ctl) Node() *node {
 	return &node{
 		kubectl: c.kubectl,
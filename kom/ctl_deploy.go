@@ -36,6 +36,11 @@ func (d *deploy) HPAList() ([]*autoscalingv2.HorizontalPodAutoscaler, error) {
 		List(&list).Error
 	return list, err
 }
+
+// Pods selects the pods managed by the Deployment, e.g. to run a command in every replica
+func (d *deploy) Pods() *pods {
+	return newPods(d.kubectl, d.ManagedPods)
+}
 func (d *deploy) ManagedPods() ([]*corev1.Pod, error) {
 	// First find the ReplicaSet
 	rs, err := d.ManagedLatestReplicaSet()
@@ -58,6 +58,10 @@ func (d *daemonSet) Restore() error {
 	return nil
 }
 
+// Pods selects the pods managed by the DaemonSet, e.g. to run a command in every replica
+func (d *daemonSet) Pods() *pods {
+	return newPods(d.kubectl, d.ManagedPods)
+}
 func (d *daemonSet) ManagedPods() ([]*corev1.Pod, error) {
 	// First find the DaemonSet
 	var ds v1.DaemonSet
@@ -1,7 +1,6 @@
 package kom
 
 import (
-	"context"
 	"fmt"
 	"html/template"
 	"strings"
@@ -13,9 +12,7 @@ import (
 	"github.com/weibaohui/kom/utils"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
-	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
@@ -122,6 +119,10 @@ func (d *node) AllNodeLabels() (map[string]string, error) {
 // Drain is typically used when a node needs maintenance.
 // It not only marks the node as unschedulable but also evicts all pods from the node one by one.
 func (d *node) Drain() error {
+	return d.kubectl.traceHelper("kom.node.drain", d.drain)
+}
+
+func (d *node) drain() error {
 	// TODO: Add handling for --force flag, which forces eviction of all pods even if they don't satisfy PDB
 	name := d.kubectl.Statement.Name
 
@@ -199,6 +200,14 @@ func (d *node) Drain() error {
 // CreateNodeShell gets a node shell
 // Requires nsenter to be present in the container
 func (d *node) CreateNodeShell(image ...string) (namespace, podName, containerName string, err error) {
+	err = d.kubectl.traceHelper("kom.node.shell", func() error {
+		namespace, podName, containerName, err = d.createNodeShell(image...)
+		return err
+	})
+	return
+}
+
+func (d *node) createNodeShell(image ...string) (namespace, podName, containerName string, err error) {
 	// Get node
 	runImage := "alpine:latest"
 	if len(image) > 0 {
@@ -248,6 +257,10 @@ spec:
 
 	// Creation successful
 	if len(ret) > 0 && strings.Contains(ret[0], "created") {
+		// Remove the shell when the cluster is closed
+		if cluster := d.kubectl.parentCluster(); cluster != nil {
+			cluster.trackPod(namespace, podName)
+		}
 		// Wait for startup or timeout, use default timeout if not specified
 		err = d.waitPodReady(namespace, podName, d.kubectl.Statement.CacheTTL)
 		return
@@ -328,6 +341,14 @@ func (d *node) waitPodReady(ns, podName string, ttl time.Duration) error {
 // Requires nsenter to be present in the container
 // CreateKubectlShell creates a Pod for running kubectl and passes in kubeconfig content
 func (d *node) CreateKubectlShell(kubeconfig string, image ...string) (namespace, podName, containerName string, err error) {
+	err = d.kubectl.traceHelper("kom.node.kubectl-shell", func() error {
+		namespace, podName, containerName, err = d.createKubectlShell(kubeconfig, image...)
+		return err
+	})
+	return
+}
+
+func (d *node) createKubectlShell(kubeconfig string, image ...string) (namespace, podName, containerName string, err error) {
 	// Default kubectl image
 	runImage := "bitnami/kubectl:latest"
 	if len(image) > 0 {
@@ -409,6 +430,10 @@ spec:
 
 	// If the return result contains "created" string, consider creation successful
 	if len(ret) > 0 && strings.Contains(ret[0], "created") {
+		// Remove the shell when the cluster is closed
+		if cluster := d.kubectl.parentCluster(); cluster != nil {
+			cluster.trackPod(namespace, podName)
+		}
 		// Wait for startup or timeout, use default timeout if not specified
 		err = d.waitPodReady(namespace, podName, d.kubectl.Statement.CacheTTL)
 
@@ -437,18 +462,12 @@ func isMirrorPod(pod *corev1.Pod) bool {
 }
 
 // Evict Pod
+// The eviction runs through the Delete callbacks, so it is checked, audited and measured like a delete.
 func (d *node) evictPod(pod *corev1.Pod) error {
 	klog.V(8).Infof("evicting pod %s/%s \n", pod.Namespace, pod.Name)
-	eviction := &policyv1.Eviction{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      pod.Name,
-			Namespace: pod.Namespace,
-		},
-	}
-	err := d.kubectl.Client().PolicyV1().Evictions(pod.Namespace).Evict(context.TODO(), eviction)
-
-	// err := d.kubectl.newInstance().Resource(eviction).Create(eviction).Error
-	if err != nil {
+	tx := d.kubectl.newInstance().Resource(pod).Namespace(pod.Namespace).Name(pod.Name)
+	tx.Statement.Evict = true
+	if err := tx.Callback().Delete().Execute(tx); err != nil {
 		return err
 	}
 	klog.V(8).Infof(" pod %s/%s evicted\n", pod.Namespace, pod.Name)
@@ -46,6 +46,14 @@ func (p *pod) Stdin(reader io.Reader) *pod {
 	tx.Statement.Stdin = reader
 	return p
 }
+
+// Stdout streams the stdout of Execute to writer instead of keeping it in memory
+func (p *pod) Stdout(writer io.Writer) *pod {
+	tx := p.kubectl.getInstance()
+	tx.Statement.Stdout = writer
+	p.kubectl = tx
+	return p
+}
 func (p *pod) GetLogs(requestPtr interface{}, opt *v1.PodLogOptions) *pod {
 	tx := p.kubectl.getInstance()
 	// If there is only one container, containerName can be omitted
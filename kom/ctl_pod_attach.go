@@ -0,0 +1,34 @@
+package kom
+
+// Attach attaches to the main process of the container and streams its output line by line until it exits or ctx is done
+// Input is read from Stdin(). This triggers Attach() type callbacks.
+//
+// Example:
+// err := kom.DefaultCluster().Namespace("default").Name("console").Ctl().Pod().ContainerName("console").Stdin(reader).
+//
+//	Attach(func(data []byte) error {
+//		fmt.Println(string(data))
+//		return nil
+//	}, nil).Error
+func (p *pod) Attach(stdout, stderr func(data []byte) error) *Kubectl {
+	tx := p.kubectl.getInstance()
+	tx.Statement.StdoutCallback = stdout
+	tx.Statement.StderrCallback = stderr
+	tx.Error = tx.Callback().Attach().Execute(tx)
+	p.Error = tx.Error
+	return tx
+}
+
+// AttachTerminal attaches term to the main process of the container, e.g. an interactive console on PID 1
+// The container must have been started with stdin, and with tty for term.TTY. It blocks until the process exits or ctx is done.
+// A TerminalResizer used as SizeQueue is closed when the session ends.
+func (p *pod) AttachTerminal(term *Terminal) *Kubectl {
+	tx := p.kubectl.getInstance()
+	tx.Statement.Terminal = term
+	tx.Error = tx.Callback().Attach().Execute(tx)
+	if resizer, ok := term.SizeQueue.(*TerminalResizer); ok {
+		resizer.Close()
+	}
+	p.Error = tx.Error
+	return tx
+}
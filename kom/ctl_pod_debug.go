@@ -0,0 +1,235 @@
+package kom
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/duke-git/lancet/v2/random"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// debugTimeout is how long Debug and DebugCopy wait for the debug container to run
+var debugTimeout = 2 * time.Minute
+
+// Debug adds an ephemeral container running image to the pod and waits until it runs, like kubectl debug -it --target
+// With a targetContainer the debug container shares its process namespace, so its processes and files under /proc/1/root are visible.
+// The container is created with stdin and a TTY, use its name with AttachTerminal, InteractiveExecute or Command.
+// The command defaults to the entrypoint of image. Ephemeral containers can not be removed, they end with their command.
+//
+// Example:
+// name, err := kom.DefaultCluster().Namespace("default").Name("distroless").Ctl().Pod().Debug("busybox", "app")
+// err = kom.DefaultCluster().Namespace("default").Name("distroless").Ctl().Pod().ContainerName(name).AttachTerminal(term).Error
+func (p *pod) Debug(image, targetContainer string, command ...string) (string, error) {
+	ns := p.kubectl.Statement.Namespace
+	name := p.kubectl.Statement.Name
+	if name == "" {
+		return "", fmt.Errorf("Name must be specified when debugging a pod")
+	}
+	if image == "" {
+		return "", fmt.Errorf("image must be specified when debugging a pod")
+	}
+
+	containerName := fmt.Sprintf("debugger-%s", strings.ToLower(random.RandString(5)))
+	ec := v1.EphemeralContainer{
+		EphemeralContainerCommon: v1.EphemeralContainerCommon{
+			Name:                     containerName,
+			Image:                    image,
+			Command:                  command,
+			ImagePullPolicy:          v1.PullIfNotPresent,
+			Stdin:                    true,
+			TTY:                      true,
+			TerminationMessagePolicy: v1.TerminationMessageReadFile,
+		},
+		TargetContainerName: targetContainer,
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"ephemeralContainers": []v1.EphemeralContainer{ec},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var item v1.Pod
+	tx := p.kubectl.newInstance().
+		WithContext(p.kubectl.Statement.Context).
+		Resource(&v1.Pod{}).
+		Namespace(ns).
+		Name(name)
+	tx.Statement.SubResource = "ephemeralcontainers"
+	err = tx.Patch(&item, types.StrategicMergePatchType, string(patch)).Error
+	if err != nil {
+		return "", fmt.Errorf("add debug container to pod %s/%s error %v", ns, name, err)
+	}
+	klog.V(6).Infof("debug container %s added to pod %s/%s", containerName, ns, name)
+
+	return containerName, p.waitForContainer(ns, name, containerName)
+}
+
+// DebugCopyOptions describes the copy made by DebugCopy
+type DebugCopyOptions struct {
+	Name                  string            // Name of the copy, <pod>-debug by default
+	Images                map[string]string // New image by container name, "*" changes all containers
+	Container             string            // Container whose command is replaced
+	Command               []string          // New command of Container, e.g. sleep infinity to keep a crashing container running
+	ShareProcessNamespace bool              // Let the containers see each other's processes
+	KeepLabels            bool              // Keep the labels, so the copy receives Service traffic
+}
+
+// DebugCopy creates a copy of the pod with changed images or command and waits until it runs, like kubectl debug --copy-to
+// Probes are removed so a changed command is not restarted, and the copy may be scheduled on any node.
+// The copy is not deleted automatically.
+//
+// Example:
+//
+//	copied, err := kom.DefaultCluster().Namespace("default").Name("nginx").Ctl().Pod().DebugCopy(kom.DebugCopyOptions{
+//		Container: "nginx",
+//		Command:   []string{"sleep", "infinity"},
+//	})
+func (p *pod) DebugCopy(opts DebugCopyOptions) (*v1.Pod, error) {
+	ns := p.kubectl.Statement.Namespace
+	name := p.kubectl.Statement.Name
+	if name == "" {
+		return nil, fmt.Errorf("Name must be specified when debugging a pod")
+	}
+
+	var origin v1.Pod
+	err := p.kubectl.newInstance().
+		WithContext(p.kubectl.Statement.Context).
+		Resource(&v1.Pod{}).
+		Namespace(ns).
+		Name(name).
+		Get(&origin).Error
+	if err != nil {
+		return nil, fmt.Errorf("get pod %s/%s error %v", ns, name, err)
+	}
+
+	copied, err := debugCopyOf(&origin, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = p.kubectl.newInstance().
+		WithContext(p.kubectl.Statement.Context).
+		Resource(copied).
+		Namespace(ns).
+		Create(copied).Error
+	if err != nil {
+		return nil, fmt.Errorf("create debug copy %s/%s error %v", ns, copied.Name, err)
+	}
+	klog.V(6).Infof("debug copy %s/%s of pod %s created", ns, copied.Name, name)
+
+	return copied, p.waitForContainer(ns, copied.Name, "")
+}
+
+// debugCopyOf returns the pod to create for a debug copy of origin
+func debugCopyOf(origin *v1.Pod, opts DebugCopyOptions) (*v1.Pod, error) {
+	copied := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        opts.Name,
+			Namespace:   origin.Namespace,
+			Annotations: origin.Annotations,
+		},
+		Spec: *origin.Spec.DeepCopy(),
+	}
+	if copied.Name == "" {
+		copied.Name = origin.Name + "-debug"
+	}
+	if opts.KeepLabels {
+		copied.Labels = origin.Labels
+	}
+	copied.Spec.NodeName = ""
+	copied.Spec.EphemeralContainers = nil
+	if opts.ShareProcessNamespace {
+		copied.Spec.ShareProcessNamespace = &opts.ShareProcessNamespace
+	}
+
+	found := opts.Container == ""
+	for i := range copied.Spec.Containers {
+		c := &copied.Spec.Containers[i]
+		c.LivenessProbe, c.ReadinessProbe, c.StartupProbe = nil, nil, nil
+		if image, ok := opts.Images[c.Name]; ok {
+			c.Image = image
+		} else if image, ok := opts.Images["*"]; ok {
+			c.Image = image
+		}
+		if c.Name == opts.Container {
+			found = true
+			if len(opts.Command) > 0 {
+				c.Command = opts.Command
+				c.Args = nil
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("container %s not found in pod %s/%s", opts.Container, origin.Namespace, origin.Name)
+	}
+	return copied, nil
+}
+
+// waitForContainer waits until the container runs, or all containers of the pod when container is empty
+func (p *pod) waitForContainer(ns, name, container string) error {
+	ctx := p.kubectl.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	err := wait.PollUntilContextTimeout(ctx, time.Second, debugTimeout, true, func(ctx context.Context) (bool, error) {
+		var item v1.Pod
+		err := p.kubectl.newInstance().
+			WithContext(ctx).
+			Resource(&v1.Pod{}).
+			Namespace(ns).
+			Name(name).
+			Get(&item).Error
+		if err != nil {
+			klog.V(6).Infof("waiting for pod %s/%s: %v", ns, name, err)
+			return false, nil
+		}
+
+		statuses := item.Status.ContainerStatuses
+		if container != "" {
+			statuses = item.Status.EphemeralContainerStatuses
+		}
+		running := 0
+		for _, status := range statuses {
+			if container != "" && status.Name != container {
+				continue
+			}
+			if err := containerStartError(ns, name, status); err != nil {
+				return false, err
+			}
+			if status.State.Running != nil {
+				running++
+			}
+		}
+		if container != "" {
+			return running == 1, nil
+		}
+		return item.Status.Phase == v1.PodRunning && running == len(item.Spec.Containers), nil
+	})
+	if err != nil {
+		return fmt.Errorf("wait for pod %s/%s to run error %v", ns, name, err)
+	}
+	return nil
+}
+
+// containerStartError reports a container that will not start
+func containerStartError(ns, name string, status v1.ContainerStatus) error {
+	if t := status.State.Terminated; t != nil {
+		return fmt.Errorf("container %s in pod %s/%s terminated: %s %s", status.Name, ns, name, t.Reason, t.Message)
+	}
+	if w := status.State.Waiting; w != nil {
+		switch w.Reason {
+		case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "CreateContainerError", "CreateContainerConfigError":
+			return fmt.Errorf("container %s in pod %s/%s can not start: %s %s", status.Name, ns, name, w.Reason, w.Message)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package kom
+
+import (
+	"time"
+)
+
+// ExecResult is the outcome of a command run with Execute
+// A command that ran and exited non-zero is reported in ExitCode, not as an error.
+// An error means the command could not be run, e.g. the container was not reachable, or it timed out.
+type ExecResult struct {
+	Stdout    []byte        `json:"stdout"`
+	Stderr    []byte        `json:"stderr"`
+	ExitCode  int           `json:"exitCode"`
+	Duration  time.Duration `json:"duration"`
+	Truncated bool          `json:"truncated,omitempty"` // Output exceeded the limit set with MaxOutput
+}
+
+// Success reports whether the command exited with code 0
+func (r *ExecResult) Success() bool {
+	return r.ExitCode == 0
+}
+
+// Timeout stops the command and fails with a timeout error when it runs longer than d
+func (p *pod) Timeout(d time.Duration) *pod {
+	tx := p.kubectl.getInstance()
+	tx.Statement.ExecTimeout = d
+	p.kubectl = tx
+	return p
+}
+
+// MaxOutput keeps at most limit bytes of stdout and of stderr, the rest is discarded
+func (p *pod) MaxOutput(limit int) *pod {
+	tx := p.kubectl.getInstance()
+	tx.Statement.ExecOutputLimit = limit
+	p.kubectl = tx
+	return p
+}
+
+// Run executes the command and returns its ExecResult
+//
+// Example:
+// result, err := kom.DefaultCluster().Namespace("default").Name("nginx").Ctl().Pod().ContainerName("nginx").Command("ls", "/data").Timeout(10 * time.Second).Run()
+//
+//	if err == nil && !result.Success() {
+//		fmt.Printf("exit code %d: %s", result.ExitCode, result.Stderr)
+//	}
+func (p *pod) Run() (*ExecResult, error) {
+	var result ExecResult
+	err := p.Execute(&result).Error
+	return &result, err
+}
@@ -3,12 +3,13 @@ package kom
 import (
 	"archive/tar"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"strings"
 
-	"github.com/weibaohui/kom/utils"
 	"k8s.io/klog/v2"
 )
 
@@ -20,40 +21,56 @@ type FileInfo struct {
 	Owner       string `json:"owner"`
 	Group       string `json:"group"`
 	Size        int64  `json:"size"`
-	ModTime     string `json:"modTime"`
-	Path        string `json:"path"`  // Storage path
-	IsDir       bool   `json:"isDir"` // Indicates whether it's a directory
+	ModTime     string `json:"modTime"` // Like ls -l, e.g. "Jan 2 15:04", FileStat.ModTime has the full time
+	Path        string `json:"path"`    // Storage path
+	IsDir       bool   `json:"isDir"`   // Indicates whether it's a directory
 }
 
-// ListFiles gets the list of files and directories at the specified path in the container
+// ListFiles gets the list of files and directories at the specified path in the container, hidden files are skipped
+// It is based on ReadDir, so it needs find, stat and readlink and uses the FileHelper when they are missing.
 func (p *pod) ListFiles(path string) ([]*FileInfo, error) {
-	klog.V(6).Infof("ListFiles %s from [%s/%s:%s]\n", path, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
-
-	var result []byte
-	err := p.Command("ls", "-l", path).Execute(&result).Error
-	if err != nil {
-		return nil, fmt.Errorf("error executing ListFiles: %v", err)
-	}
-
-	return parseFileList(path, string(result)), nil
+	return p.listFiles(path, false)
 }
+
+// ListAllFiles gets the list of files and directories at the specified path in the container, including hidden files
 func (p *pod) ListAllFiles(path string) ([]*FileInfo, error) {
+	return p.listFiles(path, true)
+}
+
+func (p *pod) listFiles(path string, all bool) ([]*FileInfo, error) {
 	klog.V(6).Infof("ListFiles %s from [%s/%s:%s]\n", path, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
 
-	var result []byte
-	err := p.Command("ls", "-l", "-a", path).Execute(&result).Error
+	stats, err := p.ReadDir(path)
 	if err != nil {
-		return nil, fmt.Errorf("error executing ListFiles: %v", err)
+		return nil, fmt.Errorf("error executing ListFiles: %w", err)
 	}
-
-	return parseFileList(path, string(result)), nil
+	var nodes []*FileInfo
+	for _, stat := range stats {
+		if !all && strings.HasPrefix(stat.Name, ".") {
+			continue
+		}
+		nodes = append(nodes, &FileInfo{
+			Name:        stat.Name,
+			Type:        stat.Type,
+			Permissions: lsPermissions(stat.Mode),
+			Owner:       stat.Owner,
+			Group:       stat.Group,
+			Size:        stat.Size,
+			ModTime:     lsModTime(stat.ModTime),
+			Path:        stat.Path,
+			IsDir:       stat.IsDir(),
+		})
+	}
+	return nodes, nil
 }
+
+// DownloadFile returns the content of filePath, use Download to stream large files
 func (p *pod) DownloadFile(filePath string) ([]byte, error) {
 	klog.V(6).Infof("DownloadFile %s from [%s/%s:%s]\n", filePath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
 
 	result, err := p.DownloadTarFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("error executing DownloadTarFile: %v", err)
+		return nil, fmt.Errorf("error executing DownloadTarFile: %w", err)
 	}
 
 	tr := tar.NewReader(bytes.NewReader(result))
@@ -95,20 +112,29 @@ func (p *pod) DownloadFile(filePath string) ([]byte, error) {
 func (p *pod) DownloadTarFile(filePath string) ([]byte, error) {
 	klog.V(6).Infof("DownloadTarFile %s from [%s/%s:%s]\n", filePath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
 
-	var result []byte
-	err := p.Command("tar", "cf", "-", filePath).Execute(&result).Error
+	rel := strings.TrimPrefix(filePath, "/")
+	if rel == "" {
+		rel = "."
+	}
+	result, err := p.runFile([]string{"tar"}, nil, nil, func(root string) []string {
+		return []string{"tar", "cf", "-", "-C", inRoot(root, "/"), rel}
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error executing DownloadTarFile: %v", err)
+		return nil, fmt.Errorf("error executing DownloadTarFile: %w", err)
 	}
 
 	return result, nil
 }
 func (p *pod) DeleteFile(filePath string) ([]byte, error) {
 	klog.V(6).Infof("DeleteFile %s from [%s/%s:%s]\n", filePath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
-	var result []byte
-	err := p.Command("rm", "-rf", filePath).Execute(&result).Error
+	if path.Clean("/"+filePath) == "/" {
+		return nil, fmt.Errorf("refusing to delete the root directory")
+	}
+	result, err := p.runFile([]string{"rm"}, nil, nil, func(root string) []string {
+		return []string{"rm", "-rf", inRoot(root, filePath)}
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error executing DeleteFile : %v", err)
+		return nil, fmt.Errorf("error executing DeleteFile: %w", err)
 	}
 
 	return result, nil
@@ -118,27 +144,31 @@ func (p *pod) DeleteFile(filePath string) ([]byte, error) {
 func (p *pod) UploadFile(destPath string, file *os.File) error {
 	klog.V(6).Infof("UploadFile %s to [%s/%s:%s] \n", destPath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
 
-	// Read and compress file content
-	var buf bytes.Buffer
-	if err := createTar(file, &buf); err != nil {
-		panic(err.Error())
+	// The tar is streamed, so large files are not held in memory
+	pr, pw := io.Pipe()
+	written := make(chan error, 1)
+	go func() {
+		err := createTar(file, pw)
+		pw.CloseWithError(err)
+		written <- err
+	}()
+	_, err := p.runFile([]string{"tar"}, pr, nil, func(root string) []string {
+		return []string{"tar", "-xmf", "-", "-C", inRoot(root, destPath)}
+	})
+	pr.CloseWithError(errTransferEnded)
+	if werr := <-written; werr != nil && !errors.Is(werr, errTransferEnded) {
+		return fmt.Errorf("error creating tar of %s: %v", file.Name(), werr)
 	}
-	var result []byte
-	err := p.
-		Stdin(&buf).
-		Command("tar", "-xmf", "-", "-C", destPath).
-		Execute(&result).Error
 	if err != nil {
-		return fmt.Errorf("error executing UploadFile: %v", err)
+		return fmt.Errorf("error executing UploadFile: %w", err)
 	}
 	return nil
 }
 
-// createTar creates a tar format compressed file
-func createTar(file *os.File, buf *bytes.Buffer) error {
+// createTar writes a tar holding file to w
+func createTar(file *os.File, w io.Writer) error {
 	// Create tar writer
-	tw := tar.NewWriter(buf)
-	defer tw.Close()
+	tw := tar.NewWriter(w)
 
 	// Get file information
 	stat, err := file.Stat()
@@ -157,8 +187,10 @@ func createTar(file *os.File, buf *bytes.Buffer) error {
 	}
 
 	// Write file content to tar
-	_, err = io.Copy(tw, file)
-	return err
+	if _, err = io.Copy(tw, file); err != nil {
+		return err
+	}
+	return tw.Close()
 }
 
 // SaveFile
@@ -186,100 +218,12 @@ func (p *pod) SaveFile(destPath string, context string) error {
 	klog.V(6).Infof("SaveFile %s to [%s/%s:%s]\n", destPath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
 	klog.V(8).Infof("SaveFile %s \n", context)
 
-	var result []byte
-	err := p.
-		Stdin(strings.NewReader(context)).
-		Command("sh", "-c", fmt.Sprintf("cat > %s", destPath)).
-		Execute(&result).Error
+	// dd writes stdin to the file without a shell, so paths with spaces work
+	_, err := p.runFile([]string{"dd"}, strings.NewReader(context), nil, func(root string) []string {
+		return []string{"dd", "of=" + inRoot(root, destPath)}
+	})
 	if err != nil {
-		return fmt.Errorf("error executing command: %v", err)
+		return fmt.Errorf("error executing SaveFile: %w", err)
 	}
 	return nil
 }
-
-// getFileType gets the file type based on file permissions
-//
-// l represents symbolic link
-// - represents regular file
-// d represents directory
-// b represents block device
-// c represents character device
-// p represents named pipe
-// s represents socket
-func getFileType(permissions string) string {
-	// Get file type flag
-	p := permissions[0]
-	var fileType string
-
-	switch p {
-	case 'd':
-		fileType = "directory" // Directory
-	case '-':
-		fileType = "file" // Regular file
-	case 'l':
-		fileType = "link" // Symbolic link
-	case 'b':
-		fileType = "block" // Block device
-	case 'c':
-		fileType = "character" // Character device
-	case 'p':
-		fileType = "pipe" // Named pipe
-	case 's':
-		fileType = "socket" // Socket
-	default:
-		fileType = "unknown" // Unknown type
-	}
-
-	return fileType
-}
-
-// parseFileList parses output and generates FileInfo list
-func parseFileList(path, output string) []*FileInfo {
-	var nodes []*FileInfo
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-		parts := strings.Fields(line)
-		klog.V(6).Infof("parseFileList path %s %s\n", path, line)
-		if len(parts) < 9 {
-			continue // Incomplete line
-		}
-
-		permissions := parts[0]
-		name := parts[8]
-		size := parts[4]
-		owner := parts[2]
-		group := parts[3]
-		modTime := strings.Join(parts[5:8], " ")
-
-		// Determine file type
-
-		fileType := getFileType(permissions)
-
-		// Package into FileInfo
-		node := FileInfo{
-			Path:        fmt.Sprintf("/%s", name),
-			Name:        name,
-			Type:        fileType,
-			Owner:       owner,
-			Group:       group,
-			Permissions: permissions,
-			Size:        utils.ToInt64(size),
-			ModTime:     modTime,
-			IsDir:       fileType == "directory",
-		}
-		if strings.HasPrefix(name, "/") {
-			node.Path = name
-		} else if path != "/" && path != name {
-			node.Path = fmt.Sprintf("%s/%s", path, name)
-		} else {
-			node.Path = fmt.Sprintf("/%s", name)
-		}
-
-		nodes = append(nodes, &node)
-	}
-
-	return nodes
-}
@@ -0,0 +1,417 @@
+package kom
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// statFormat prints raw mode in hex, size, mtime, uid, gid, owner, group and the name last, so names may contain spaces
+const statFormat = "%f %s %Y %u %g %U %G %n"
+
+// fileHelperRoot is the root of the target container's file system seen from the file helper container
+const fileHelperRoot = "/proc/1/root"
+
+// fileHelperCommand keeps the file helper container running, so it is reused by later file operations
+var fileHelperCommand = []string{"sleep", "2147483647"}
+
+// statTools are the commands Stat and ReadDir run in the container
+var statTools = []string{"find", "stat", "readlink"}
+
+// FileStat is the stat of a file in a container
+type FileStat struct {
+	Name       string      `json:"name"`
+	Path       string      `json:"path"`
+	Type       string      `json:"type"` // file, directory, link, block, character, pipe or socket
+	Mode       os.FileMode `json:"mode"` // Permission and type bits
+	Size       int64       `json:"size"`
+	ModTime    time.Time   `json:"modTime"`
+	UID        int         `json:"uid"`
+	GID        int         `json:"gid"`
+	Owner      string      `json:"owner"`
+	Group      string      `json:"group"`
+	LinkTarget string      `json:"linkTarget,omitempty"` // Target of a symbolic link, as stored in the link
+}
+
+// IsDir reports whether the file is a directory
+func (s *FileStat) IsDir() bool {
+	return s.Mode.IsDir()
+}
+
+// MissingToolError reports commands a file operation needs that the container does not have
+type MissingToolError struct {
+	Namespace string
+	Pod       string
+	Container string
+	Tools     []string
+}
+
+func (e *MissingToolError) Error() string {
+	return fmt.Sprintf("container %s of pod %s/%s lacks %s, use FileHelper to run file operations in a helper container",
+		e.Container, e.Namespace, e.Pod, strings.Join(e.Tools, ", "))
+}
+
+// FileHelper runs file operations in an ephemeral container with image, e.g. busybox, when the container lacks their commands
+// The helper targets the container and reaches its files under /proc/1/root, which needs a pod without shareProcessNamespace.
+// It is created once and reused, ephemeral containers can not be removed.
+//
+// Example:
+// files, err := kom.DefaultCluster().Namespace("default").Name("distroless").Ctl().Pod().ContainerName("app").FileHelper("busybox").ReadDir("/app")
+func (p *pod) FileHelper(image string) *pod {
+	tx := p.kubectl.getInstance()
+	tx.Statement.FileHelperImage = image
+	p.kubectl = tx
+	return p
+}
+
+// Stat returns the stat of path, a symbolic link is not followed
+// It needs find, stat and readlink, as in coreutils or busybox.
+func (p *pod) Stat(path string) (*FileStat, error) {
+	klog.V(6).Infof("Stat %s from [%s/%s:%s]\n", path, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+
+	stats, err := p.findStat(path, "-maxdepth", "0")
+	if err != nil {
+		return nil, err
+	}
+	if len(stats) != 1 {
+		return nil, fmt.Errorf("stat %s returned %d entries", path, len(stats))
+	}
+	return stats[0], nil
+}
+
+// ReadDir returns the stat of every entry in the directory path, including hidden ones
+// It needs find, stat and readlink, as in coreutils or busybox.
+//
+// Example:
+// files, err := kom.DefaultCluster().Namespace("default").Name("nginx").Ctl().Pod().ContainerName("nginx").ReadDir("/etc")
+func (p *pod) ReadDir(path string) ([]*FileStat, error) {
+	klog.V(6).Infof("ReadDir %s from [%s/%s:%s]\n", path, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+
+	// The trailing slash follows a link to a directory
+	return p.findStat(strings.TrimSuffix(path, "/")+"/", "-mindepth", "1", "-maxdepth", "1")
+}
+
+// findStat runs stat on the files found at path, followed by the target of each link
+func (p *pod) findStat(path string, depth ...string) ([]*FileStat, error) {
+	var root string
+	out, err := p.runFile(statTools, nil, nil, func(r string) []string {
+		root = r
+		args := append([]string{"find", inRoot(r, path)}, depth...)
+		return append(args, "-exec", "stat", "-c", statFormat, "{}", ";", "-type", "l", "-exec", "readlink", "{}", ";")
+	})
+	if err != nil {
+		return nil, fmt.Errorf("stat %s error: %w", path, err)
+	}
+	return parseStat(root, string(out))
+}
+
+// parseStat parses lines printed with statFormat, each link is followed by a line with its target
+func parseStat(root, output string) ([]*FileStat, error) {
+	var stats []*FileStat
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		if lines[i] == "" {
+			continue
+		}
+		fields := strings.SplitN(lines[i], " ", 8)
+		if len(fields) != 8 {
+			return nil, fmt.Errorf("unexpected stat output %q", lines[i])
+		}
+		raw, err := strconv.ParseUint(fields[0], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected mode in stat output %q", lines[i])
+		}
+		size, _ := strconv.ParseInt(fields[1], 10, 64)
+		mtime, _ := strconv.ParseInt(fields[2], 10, 64)
+		uid, _ := strconv.Atoi(fields[3])
+		gid, _ := strconv.Atoi(fields[4])
+
+		path := strings.TrimPrefix(fields[7], root)
+		if path != "/" {
+			path = strings.TrimSuffix(path, "/")
+		}
+		mode := fileModeOf(uint32(raw))
+		stat := &FileStat{
+			Name:    path[strings.LastIndex(path, "/")+1:],
+			Path:    path,
+			Type:    fileTypeOf(mode),
+			Mode:    mode,
+			Size:    size,
+			ModTime: time.Unix(mtime, 0),
+			UID:     uid,
+			GID:     gid,
+			Owner:   fields[5],
+			Group:   fields[6],
+		}
+		if path == "/" {
+			stat.Name = "/"
+		}
+		if mode&os.ModeSymlink != 0 && i+1 < len(lines) {
+			i++
+			stat.LinkTarget = lines[i]
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
+
+// fileModeOf converts a raw st_mode to an os.FileMode
+func fileModeOf(raw uint32) os.FileMode {
+	mode := os.FileMode(raw & 0o777)
+	if raw&0o4000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if raw&0o2000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if raw&0o1000 != 0 {
+		mode |= os.ModeSticky
+	}
+	switch raw & 0o170000 {
+	case 0o040000:
+		mode |= os.ModeDir
+	case 0o120000:
+		mode |= os.ModeSymlink
+	case 0o060000:
+		mode |= os.ModeDevice
+	case 0o020000:
+		mode |= os.ModeDevice | os.ModeCharDevice
+	case 0o010000:
+		mode |= os.ModeNamedPipe
+	case 0o140000:
+		mode |= os.ModeSocket
+	}
+	return mode
+}
+
+// fileTypeOf names the type of mode like FileInfo.Type
+func fileTypeOf(mode os.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "directory"
+	case mode&os.ModeSymlink != 0:
+		return "link"
+	case mode&os.ModeCharDevice != 0:
+		return "character"
+	case mode&os.ModeDevice != 0:
+		return "block"
+	case mode&os.ModeNamedPipe != 0:
+		return "pipe"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	}
+	return "file"
+}
+
+// lsModTime formats t like the time columns of ls -l, e.g. "Jan 2 15:04", or "Jan 2 2023" when older than six months
+// FileInfo.ModTime has always been in this format, as ListFiles used to parse ls -l.
+func lsModTime(t time.Time) string {
+	t = t.UTC()
+	now := time.Now()
+	if t.Before(now.AddDate(0, -6, 0)) || t.After(now.Add(time.Hour)) {
+		return t.Format("Jan 2 2006")
+	}
+	return t.Format("Jan 2 15:04")
+}
+
+// lsPermissions formats mode like ls -l, e.g. drwxr-xr-x
+func lsPermissions(mode os.FileMode) string {
+	b := []byte("-rwxrwxrwx")
+	switch fileTypeOf(mode) {
+	case "directory":
+		b[0] = 'd'
+	case "link":
+		b[0] = 'l'
+	case "character":
+		b[0] = 'c'
+	case "block":
+		b[0] = 'b'
+	case "pipe":
+		b[0] = 'p'
+	case "socket":
+		b[0] = 's'
+	}
+	for i := 0; i < 9; i++ {
+		if mode&(1<<uint(8-i)) == 0 {
+			b[i+1] = '-'
+		}
+	}
+	special := func(set bool, i int, c byte) {
+		if !set {
+			return
+		}
+		if b[i] == 'x' {
+			b[i] = c
+		} else {
+			b[i] = c - 'a' + 'A'
+		}
+	}
+	special(mode&os.ModeSetuid != 0, 3, 's')
+	special(mode&os.ModeSetgid != 0, 6, 's')
+	special(mode&os.ModeSticky != 0, 9, 't')
+	return string(b)
+}
+
+// inRoot returns path in the file system mounted at root, path itself when root is empty
+func inRoot(root, path string) string {
+	if root == "" {
+		return path
+	}
+	return root + "/" + strings.TrimPrefix(path, "/")
+}
+
+// runFile runs a file command in the container and returns its stdout, or streams it to stdout when set
+// A failed command is an error with its stderr.
+// When the container lacks one of tools, the command runs in the file helper container if one is set, else a MissingToolError is returned.
+// build returns the command for the root of the container's file system, which is empty in the container itself.
+func (p *pod) runFile(tools []string, stdin io.Reader, stdout io.Writer, build func(root string) []string) ([]byte, error) {
+	stmt := p.kubectl.Statement
+	container := stmt.ContainerName
+	if container == "" {
+		return nil, fmt.Errorf("Please call ContainerName() method to set Pod container name")
+	}
+
+	inContainer := true
+	if stmt.FileHelperImage != "" && stdin != nil {
+		// A failed run would consume stdin, so look for the tools first
+		missing, err := p.missingTools(container, tools)
+		if err != nil {
+			return nil, err
+		}
+		inContainer = len(missing) == 0
+	}
+	if inContainer {
+		cmd := build("")
+		result, err := p.execIn(container, stdin, stdout, cmd)
+		if err == nil && result.Success() {
+			return result.Stdout, nil
+		}
+		if !commandNotFound(result, err) {
+			// The tools ran, the command itself failed
+			return nil, fileCommandError(cmd, result, err)
+		}
+		missing, perr := p.missingTools(container, tools)
+		if perr != nil {
+			return nil, perr
+		}
+		if len(missing) == 0 {
+			return nil, fileCommandError(cmd, result, err)
+		}
+		if stmt.FileHelperImage == "" || stdin != nil {
+			return nil, &MissingToolError{Namespace: stmt.Namespace, Pod: stmt.Name, Container: container, Tools: missing}
+		}
+		klog.V(6).Infof("container %s lacks %v, using file helper %s", container, missing, stmt.FileHelperImage)
+	}
+
+	helper, err := p.fileHelper()
+	if err != nil {
+		return nil, err
+	}
+	cmd := build(fileHelperRoot)
+	result, err := p.execIn(helper, stdin, stdout, cmd)
+	if err == nil && result.Success() {
+		return result.Stdout, nil
+	}
+	if !commandNotFound(result, err) {
+		return nil, fileCommandError(cmd, result, err)
+	}
+	if missing, _ := p.missingTools(helper, tools); len(missing) > 0 {
+		return nil, fmt.Errorf("file helper image %s lacks %s", stmt.FileHelperImage, strings.Join(missing, ", "))
+	}
+	return nil, fileCommandError(cmd, result, err)
+}
+
+// fileCommandError describes a file command that could not run or failed
+func fileCommandError(cmd []string, result *ExecResult, err error) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%s exited with %d: %s", cmd[0], result.ExitCode, strings.TrimSpace(string(result.Stderr)))
+}
+
+// execIn runs cmd in container of the pod
+func (p *pod) execIn(container string, stdin io.Reader, stdout io.Writer, cmd []string) (*ExecResult, error) {
+	stmt := p.kubectl.Statement
+	tx := p.kubectl.newInstance().
+		WithContext(stmt.Context).
+		Resource(&v1.Pod{}).
+		Namespace(stmt.Namespace).
+		Name(stmt.Name).
+		Ctl().Pod().
+		ContainerName(container).
+		Timeout(stmt.ExecTimeout)
+	if stdin != nil {
+		tx = tx.Stdin(stdin)
+	}
+	if stdout != nil {
+		tx = tx.Stdout(stdout)
+	}
+	return tx.Command(cmd[0], cmd[1:]...).Run()
+}
+
+// missingTools returns the tools that can not be run in container
+func (p *pod) missingTools(container string, tools []string) ([]string, error) {
+	var missing []string
+	for _, tool := range tools {
+		result, err := p.execIn(container, nil, nil, []string{tool, "--help"})
+		if err != nil && !isMissingExec(err) {
+			return nil, err
+		}
+		if err != nil || result.ExitCode == 126 || result.ExitCode == 127 {
+			missing = append(missing, tool)
+		}
+	}
+	return missing, nil
+}
+
+// commandNotFound reports whether a failed command could not be started, so one of its tools may be missing
+// Runtimes report a missing executable as an exec error, shells exit with 127, or 126 when it cannot be executed.
+func commandNotFound(result *ExecResult, err error) bool {
+	if err != nil {
+		return isMissingExec(err)
+	}
+	return result.ExitCode == 126 || result.ExitCode == 127
+}
+
+// isMissingExec reports whether the container runtime could not find the executable of a command
+func isMissingExec(err error) bool {
+	s := err.Error()
+	return strings.Contains(s, "executable file not found") || strings.Contains(s, "no such file or directory")
+}
+
+// fileHelper returns the running file helper container of the pod, adding one when there is none
+func (p *pod) fileHelper() (string, error) {
+	stmt := p.kubectl.Statement
+	var item v1.Pod
+	err := p.kubectl.newInstance().
+		WithContext(stmt.Context).
+		Resource(&v1.Pod{}).
+		Namespace(stmt.Namespace).
+		Name(stmt.Name).
+		Get(&item).Error
+	if err != nil {
+		return "", fmt.Errorf("get pod %s/%s error %v", stmt.Namespace, stmt.Name, err)
+	}
+	if item.Spec.ShareProcessNamespace != nil && *item.Spec.ShareProcessNamespace {
+		return "", fmt.Errorf("pod %s/%s shares its process namespace, the file helper can not reach the files of container %s", stmt.Namespace, stmt.Name, stmt.ContainerName)
+	}
+
+	running := map[string]bool{}
+	for _, status := range item.Status.EphemeralContainerStatuses {
+		running[status.Name] = status.State.Running != nil
+	}
+	for _, ec := range item.Spec.EphemeralContainers {
+		if ec.TargetContainerName == stmt.ContainerName && ec.Image == stmt.FileHelperImage &&
+			slices.Equal(ec.Command, fileHelperCommand) && running[ec.Name] {
+			return ec.Name, nil
+		}
+	}
+	return p.Debug(stmt.FileHelperImage, stmt.ContainerName, fileHelperCommand...)
+}
@@ -58,20 +58,24 @@ func (p *pod) LinkedService() ([]*v1.Service, error) {
 
 	var result []*v1.Service
 	for _, svc := range services {
-		serviceLabels := svc.Spec.Selector
-		// If empty, indicates no specific pod selector, skip this svc
-		if len(serviceLabels) == 0 {
-			continue
-		}
-		// Iterate through selector
-		// All kv pairs in serviceLabels must exist in podLabels with matching values
-		if utils.CompareMapContains(serviceLabels, podLabels) {
+		if serviceSelects(svc, pod) {
 			result = append(result, svc)
 		}
 	}
 	return result, nil
 }
 
+// serviceSelects reports whether the selector of svc matches the labels of pod
+func serviceSelects(svc *v1.Service, pod *v1.Pod) bool {
+	serviceLabels := svc.Spec.Selector
+	// If empty, indicates no specific pod selector, skip this svc
+	if len(serviceLabels) == 0 {
+		return false
+	}
+	// All kv pairs in serviceLabels must exist in podLabels with matching values
+	return utils.CompareMapContains(serviceLabels, pod.GetLabels())
+}
+
 func (p *pod) LinkedEndpoints() ([]*v1.Endpoints, error) {
 
 	services, err := p.LinkedService()
@@ -90,13 +94,16 @@ func (p *pod) LinkedEndpoints() ([]*v1.Endpoints, error) {
 	for _, svc := range services {
 		names = append(names, svc.Name)
 	}
+	return linkedEndpoints(p.kubectl, names)
+}
 
+// linkedEndpoints lists the endpoints of the named services in the namespace of k
+func linkedEndpoints(k *Kubectl, names []string) ([]*v1.Endpoints, error) {
 	var endpoints []*v1.Endpoints
-
-	err = p.kubectl.newInstance().
-		WithContext(p.kubectl.Statement.Context).
+	err := k.newInstance().
+		WithContext(k.Statement.Context).
 		Resource(&v1.Endpoints{}).
-		Namespace(p.kubectl.Statement.Namespace).
+		Namespace(k.Statement.Namespace).
 		Where("metadata.name in " + utils.StringListToSQLIn(names)).
 		RemoveManagedFields().
 		List(&endpoints).Error
@@ -0,0 +1,244 @@
+package kom
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/weibaohui/kom/utils"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// ErrStopLogs can be returned by the line callback of StreamLogs to stop streaming without an error
+var ErrStopLogs = errors.New("stop streaming logs")
+
+// logReconnectInterval is the delay between attempts to reopen a followed log stream
+var logReconnectInterval = time.Second
+
+// LogOptions builds the options of StreamLogs
+type LogOptions struct {
+	opts v1.PodLogOptions
+}
+
+// NewLogOptions returns options that read the current logs once
+func NewLogOptions() *LogOptions {
+	return &LogOptions{}
+}
+
+// Follow keeps streaming new lines, reconnecting when the container restarts
+func (o *LogOptions) Follow() *LogOptions {
+	o.opts.Follow = true
+	return o
+}
+
+// Since starts with the lines written in the last d
+func (o *LogOptions) Since(d time.Duration) *LogOptions {
+	o.opts.SinceSeconds = utils.Ptr(int64(d.Seconds()))
+	o.opts.SinceTime = nil
+	return o
+}
+
+// SinceTime starts with the lines written after t
+func (o *LogOptions) SinceTime(t time.Time) *LogOptions {
+	o.opts.SinceTime = &metav1.Time{Time: t}
+	o.opts.SinceSeconds = nil
+	return o
+}
+
+// Previous reads the logs of the previous, terminated instance of the container
+func (o *LogOptions) Previous() *LogOptions {
+	o.opts.Previous = true
+	return o
+}
+
+// Timestamps prefixes every line with its RFC3339 timestamp
+func (o *LogOptions) Timestamps() *LogOptions {
+	o.opts.Timestamps = true
+	return o
+}
+
+// Tail starts with the last lines lines
+func (o *LogOptions) Tail(lines int64) *LogOptions {
+	o.opts.TailLines = utils.Ptr(lines)
+	return o
+}
+
+// PodLogOptions returns the options as used by GetLogs
+func (o *LogOptions) PodLogOptions() *v1.PodLogOptions {
+	opts := o.opts
+	return &opts
+}
+
+// StreamLogs calls fn with every log line of the container, without the line break
+// With Follow it ends when the context ends, the pod is deleted or completed, or fn returns an error.
+// Return ErrStopLogs from fn to stop without an error. Every connection triggers Logs() type callbacks.
+//
+// Example:
+//
+//	err := kom.DefaultCluster().Namespace("default").Name("nginx").Ctl().Pod().ContainerName("nginx").
+//		StreamLogs(kom.NewLogOptions().Follow().Tail(100), func(line []byte) error {
+//			fmt.Println(string(line))
+//			return nil
+//		}).Error
+func (p *pod) StreamLogs(opts *LogOptions, fn func(line []byte) error) *Kubectl {
+	tx := p.kubectl.getInstance()
+	if opts == nil {
+		opts = NewLogOptions()
+	}
+	tx.Error = p.streamLogs(tx, opts.opts, fn)
+	p.Error = tx.Error
+	return tx
+}
+
+func (p *pod) streamLogs(tx *Kubectl, opts v1.PodLogOptions, fn func(line []byte) error) error {
+	ctx := tx.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	userTimestamps := opts.Timestamps
+	if opts.Follow {
+		// Timestamps tell which lines were already seen after a reconnect
+		opts.Timestamps = true
+	}
+
+	var last time.Time
+	connected := false
+	for {
+		var stream io.ReadCloser
+		request := opts
+		err := tx.Ctl().Pod().GetLogs(&stream, &request).Error
+		if err == nil {
+			reconnect := connected
+			connected = true
+			var fnErr error
+			fnErr, err = readLogLines(stream, func(line []byte) error {
+				if !opts.Follow {
+					return fn(line)
+				}
+				ts, rest := splitLogTimestamp(line)
+				if reconnect && !ts.IsZero() && !ts.After(last) {
+					// Already seen before the reconnect
+					return nil
+				}
+				if !ts.IsZero() {
+					last = ts
+				}
+				if !userTimestamps {
+					line = rest
+				}
+				return fn(line)
+			})
+			_ = stream.Close()
+			if errors.Is(fnErr, ErrStopLogs) {
+				return nil
+			}
+			if fnErr != nil {
+				return fnErr
+			}
+		}
+		// A follow that never connected fails like a single read, e.g. for a wrong container name
+		if !opts.Follow || !connected || ctx.Err() != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if !connected && err != nil {
+				return &logsNotConnectedError{err: err}
+			}
+			return err
+		}
+
+		// The stream ended or could not be reopened, most likely because the container exited
+		if done := p.waitForLogs(tx, ctx); done {
+			return nil
+		}
+		klog.V(6).Infof("reconnecting logs of %s/%s:%s", tx.Statement.Namespace, tx.Statement.Name, tx.Statement.ContainerName)
+		if !last.IsZero() {
+			opts.SinceTime = &metav1.Time{Time: last}
+			opts.SinceSeconds = nil
+			opts.TailLines = nil
+		}
+		opts.Previous = false
+	}
+}
+
+// logsNotConnectedError is returned when the logs could not be opened at all, e.g. the container has not started yet
+type logsNotConnectedError struct {
+	err error
+}
+
+func (e *logsNotConnectedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *logsNotConnectedError) Unwrap() error {
+	return e.err
+}
+
+// waitForLogs waits before reconnecting, returns true when the pod will not write logs anymore
+func (p *pod) waitForLogs(tx *Kubectl, ctx context.Context) bool {
+	timer := time.NewTimer(logReconnectInterval)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return true
+	case <-timer.C:
+	}
+
+	var item v1.Pod
+	err := tx.newInstance().WithContext(ctx).
+		Resource(&v1.Pod{}).
+		Namespace(tx.Statement.Namespace).
+		Name(tx.Statement.Name).
+		Get(&item).Error
+	if apierrors.IsNotFound(err) {
+		return true
+	}
+	if err != nil {
+		// Retry later, e.g. while the API server is not reachable
+		return ctx.Err() != nil
+	}
+	if item.Status.Phase == v1.PodSucceeded || item.Status.Phase == v1.PodFailed {
+		return true
+	}
+	return false
+}
+
+// readLogLines calls fn for every line of stream, returns the error of fn or of reading separately
+func readLogLines(stream io.Reader, fn func(line []byte) error) (fnErr error, readErr error) {
+	reader := bufio.NewReader(stream)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			line = bytes.TrimRight(line, "\r\n")
+			if err := fn(line); err != nil {
+				return err, nil
+			}
+		}
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read logs error %v", err)
+		}
+	}
+}
+
+// splitLogTimestamp splits the RFC3339 timestamp added by the Timestamps option from line
+func splitLogTimestamp(line []byte) (time.Time, []byte) {
+	i := bytes.IndexByte(line, ' ')
+	if i < 0 {
+		return time.Time{}, line
+	}
+	ts, err := time.Parse(time.RFC3339Nano, string(line[:i]))
+	if err != nil {
+		return time.Time{}, line
+	}
+	return ts, line[i+1:]
+}
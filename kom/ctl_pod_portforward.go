@@ -0,0 +1,107 @@
+package kom
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/client-go/tools/portforward"
+)
+
+// PortForwardRequest carries a port forward between the pod helpers and the port-forward callback
+type PortForwardRequest struct {
+	Addresses []string                   // Local addresses to listen on
+	Ports     []string                   // Ports in "local:remote" form, local 0 picks a free port
+	StopCh    chan struct{}              // Closed to stop forwarding
+	ReadyCh   chan struct{}              // Closed by the forwarder once listening
+	DoneCh    chan error                 // Receives the result of forwarding when it ends
+	Forwarder *portforward.PortForwarder // Set by the callback
+
+	stopOnce sync.Once
+	release  func() // Releases the operation context once forwarding ended
+}
+
+// Stop closes StopCh, it is safe to call more than once
+func (r *PortForwardRequest) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.StopCh)
+	})
+}
+
+// PortForwarder is a running port forward
+type PortForwarder struct {
+	req  *PortForwardRequest
+	done chan struct{}
+	err  error
+}
+
+func newPortForwarder(req *PortForwardRequest) *PortForwarder {
+	f := &PortForwarder{req: req, done: make(chan struct{})}
+	go func() {
+		f.err = <-req.DoneCh
+		if req.release != nil {
+			req.release()
+		}
+		close(f.done)
+	}()
+	return f
+}
+
+// Ready is closed once the local ports are listening
+func (f *PortForwarder) Ready() <-chan struct{} {
+	return f.req.ReadyCh
+}
+
+// Done is closed when forwarding ended, because of Stop, a lost connection, or the cluster being closed
+func (f *PortForwarder) Done() <-chan struct{} {
+	return f.done
+}
+
+// Err returns why forwarding ended, nil after Stop
+func (f *PortForwarder) Err() error {
+	select {
+	case <-f.done:
+		return f.err
+	default:
+		return nil
+	}
+}
+
+// Stop stops forwarding and closes the local listeners
+func (f *PortForwarder) Stop() {
+	f.req.Stop()
+}
+
+// Ports returns the forwarded ports, with the local port picked when 0 was requested
+func (f *PortForwarder) Ports() ([]portforward.ForwardedPort, error) {
+	if f.req.Forwarder == nil {
+		return nil, fmt.Errorf("port forward is not running")
+	}
+	return f.req.Forwarder.GetPorts()
+}
+
+// PortForward forwards localPort on localhost to podPort of the pod
+// It returns once the local port is listening. Use localPort 0 to pick a free port, see PortForwarder.Ports.
+//
+// Example:
+// fw, err := kom.DefaultCluster().Namespace("default").Name("nginx").Ctl().Pod().PortForward(8080, 80)
+// defer fw.Stop()
+func (p *pod) PortForward(localPort, podPort int, addresses ...string) (*PortForwarder, error) {
+	if len(addresses) == 0 {
+		addresses = []string{"localhost"}
+	}
+	req := &PortForwardRequest{
+		Addresses: addresses,
+		Ports:     []string{fmt.Sprintf("%d:%d", localPort, podPort)},
+		StopCh:    make(chan struct{}),
+		ReadyCh:   make(chan struct{}),
+		DoneCh:    make(chan error, 1),
+	}
+	tx := p.kubectl.getInstance()
+	tx.Statement.PortForward = req
+	tx.Error = tx.Callback().PortForward().Execute(tx)
+	p.Error = tx.Error
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return newPortForwarder(req), nil
+}
@@ -0,0 +1,84 @@
+package kom
+
+import (
+	"io"
+	"sync"
+
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// Terminal is an interactive session with a process in a container
+type Terminal struct {
+	Stdin     io.Reader                       // Keystrokes sent to the process, closing it sends EOF
+	Stdout    io.Writer                       // Output of the process
+	Stderr    io.Writer                       // Error output, unused with TTY because the terminal merges it into Stdout
+	TTY       bool                            // Allocate a terminal, needed by full-screen programs like top or vim
+	SizeQueue remotecommand.TerminalSizeQueue // Resize events, e.g. a TerminalResizer
+	Exited    bool                            // Set once the remote process exited
+	ExitCode  int                             // Exit code of the remote process
+}
+
+// TerminalResizer queues resize events of a Terminal, only the latest pending size is kept
+type TerminalResizer struct {
+	mu     sync.Mutex
+	ch     chan remotecommand.TerminalSize
+	closed bool
+}
+
+// NewTerminalResizer creates a resize queue, e.g. fed by the resize messages of a web terminal
+func NewTerminalResizer() *TerminalResizer {
+	return &TerminalResizer{ch: make(chan remotecommand.TerminalSize, 1)}
+}
+
+// Resize queues a new terminal size, it is ignored after Close
+func (r *TerminalResizer) Resize(width, height uint16) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	// Replace a size that was not sent yet
+	select {
+	case <-r.ch:
+	default:
+	}
+	r.ch <- remotecommand.TerminalSize{Width: width, Height: height}
+}
+
+// Next blocks until the next resize, returns nil once closed
+func (r *TerminalResizer) Next() *remotecommand.TerminalSize {
+	size, ok := <-r.ch
+	if !ok {
+		return nil
+	}
+	return &size
+}
+
+// Close stops the queue
+func (r *TerminalResizer) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.closed {
+		r.closed = true
+		close(r.ch)
+	}
+}
+
+// InteractiveExecute runs the command attached to term and blocks until the remote process exits
+// This triggers StreamExec() type callbacks. A non-zero exit code is reported in term.ExitCode, not as an error.
+// A TerminalResizer used as SizeQueue is closed when the session ends.
+//
+// Example:
+// resizer := kom.NewTerminalResizer()
+// term := &kom.Terminal{Stdin: stdin, Stdout: stdout, TTY: true, SizeQueue: resizer}
+// err := kom.DefaultCluster().Namespace("default").Name("nginx").Ctl().Pod().ContainerName("nginx").Command("sh").InteractiveExecute(term).Error
+func (p *pod) InteractiveExecute(term *Terminal) *Kubectl {
+	tx := p.kubectl.getInstance()
+	tx.Statement.Terminal = term
+	tx.Error = tx.Callback().StreamExec().Execute(tx)
+	if resizer, ok := term.SizeQueue.(*TerminalResizer); ok {
+		resizer.Close()
+	}
+	p.Error = tx.Error
+	return tx
+}
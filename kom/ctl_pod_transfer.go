@@ -0,0 +1,538 @@
+package kom
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// errTransferEnded closes the pipe of a directory transfer once the command in the container ended
+var errTransferEnded = errors.New("transfer ended")
+
+// TransferOptions controls Upload, Download, UploadDir and DownloadDir
+type TransferOptions struct {
+	Mode     os.FileMode            // Permissions of the file written by Upload, left to the umask when 0
+	Progress func(TransferProgress) // Called as file content is transferred
+	NoVerify bool                   // Skip comparing sha256 checksums after the transfer, which needs find and sha256sum in the container
+}
+
+// TransferProgress is the state of a transfer passed to TransferOptions.Progress
+type TransferProgress struct {
+	File  string `json:"file"`  // File being transferred, relative to the directory for UploadDir and DownloadDir
+	Bytes int64  `json:"bytes"` // Content bytes transferred so far
+	Total int64  `json:"total"` // Content bytes to transfer, 0 when unknown
+	Files int    `json:"files"` // Files completed
+}
+
+// transferCounter counts written bytes and reports the progress
+type transferCounter struct {
+	progress TransferProgress
+	report   func(TransferProgress)
+}
+
+func newTransferCounter(opts *TransferOptions, total int64) *transferCounter {
+	return &transferCounter{progress: TransferProgress{Total: total}, report: opts.Progress}
+}
+
+func (c *transferCounter) Write(b []byte) (int, error) {
+	c.progress.Bytes += int64(len(b))
+	if c.report != nil {
+		c.report(c.progress)
+	}
+	return len(b), nil
+}
+
+// start begins a file, done ends it
+func (c *transferCounter) start(file string) {
+	c.progress.File = file
+}
+
+func (c *transferCounter) done() {
+	c.progress.Files++
+	if c.report != nil {
+		c.report(c.progress)
+	}
+}
+
+// Upload streams r to the file destPath in the container, replacing it
+// It needs dd, chmod when opts.Mode is set, and find and sha256sum to verify the checksum. The FileHelper is used when they are missing.
+//
+// Example:
+// err := kom.DefaultCluster().Namespace("default").Name("nginx").Ctl().Pod().ContainerName("nginx").Upload("/tmp/data.bin", file, &kom.TransferOptions{Mode: 0644})
+func (p *pod) Upload(destPath string, r io.Reader, opts *TransferOptions) error {
+	klog.V(6).Infof("Upload %s to [%s/%s:%s]\n", destPath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+	if opts == nil {
+		opts = &TransferOptions{}
+	}
+
+	counter := newTransferCounter(opts, 0)
+	counter.start(destPath)
+	sum := sha256.New()
+	in := io.TeeReader(r, io.MultiWriter(sum, counter))
+	_, err := p.runFile([]string{"dd"}, in, nil, func(root string) []string {
+		return []string{"dd", "of=" + inRoot(root, destPath), "bs=65536"}
+	})
+	if err != nil {
+		return fmt.Errorf("upload %s error: %w", destPath, err)
+	}
+	counter.done()
+
+	if opts.Mode != 0 {
+		_, err = p.runFile([]string{"chmod"}, nil, nil, func(root string) []string {
+			return []string{"chmod", fmt.Sprintf("%o", opts.Mode.Perm()), inRoot(root, destPath)}
+		})
+		if err != nil {
+			return fmt.Errorf("chmod %s error: %w", destPath, err)
+		}
+	}
+	if opts.NoVerify {
+		return nil
+	}
+	return p.verifyChecksums(destPath, map[string]string{destPath: hex.EncodeToString(sum.Sum(nil))}, false)
+}
+
+// Download streams the file srcPath in the container to w, without keeping it in memory
+// It needs find, stat and readlink for the size, dd, and sha256sum to verify the checksum. The FileHelper is used when they are missing.
+//
+// Example:
+// out, _ := os.Create("heap.hprof")
+// err := kom.DefaultCluster().Namespace("default").Name("java").Ctl().Pod().ContainerName("app").Download("/tmp/heap.hprof", out, nil)
+func (p *pod) Download(srcPath string, w io.Writer, opts *TransferOptions) error {
+	klog.V(6).Infof("Download %s from [%s/%s:%s]\n", srcPath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+	if opts == nil {
+		opts = &TransferOptions{}
+	}
+
+	stat, err := p.Stat(srcPath)
+	if err != nil {
+		return fmt.Errorf("download %s error: %w", srcPath, err)
+	}
+	if stat.IsDir() {
+		return fmt.Errorf("download %s error: it is a directory, use DownloadDir", srcPath)
+	}
+	var total int64
+	if stat.Type == "file" {
+		total = stat.Size
+	}
+
+	counter := newTransferCounter(opts, total)
+	counter.start(srcPath)
+	sum := sha256.New()
+	_, err = p.runFile([]string{"dd"}, nil, io.MultiWriter(w, sum, counter), func(root string) []string {
+		return []string{"dd", "if=" + inRoot(root, srcPath), "bs=65536"}
+	})
+	if err != nil {
+		return fmt.Errorf("download %s error: %w", srcPath, err)
+	}
+	counter.done()
+
+	if opts.NoVerify {
+		return nil
+	}
+	return p.verifyChecksums(srcPath, map[string]string{srcPath: hex.EncodeToString(sum.Sum(nil))}, false)
+}
+
+// UploadDir streams the local directory localDir to destDir in the container, which is created if missing
+// Permissions, modification times and symbolic links are kept, files are owned by the user extracting them.
+// It needs tar, and find and sha256sum to verify the checksums. The FileHelper is used when they are missing.
+//
+// Example:
+//
+//	err := kom.DefaultCluster().Namespace("default").Name("nginx").Ctl().Pod().ContainerName("nginx").
+//		UploadDir("./html", "/usr/share/nginx/html", &kom.TransferOptions{Progress: func(p kom.TransferProgress) {
+//			fmt.Printf("%s %d/%d\n", p.File, p.Bytes, p.Total)
+//		}})
+func (p *pod) UploadDir(localDir, destDir string, opts *TransferOptions) error {
+	klog.V(6).Infof("UploadDir %s to %s [%s/%s:%s]\n", localDir, destDir, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+	if opts == nil {
+		opts = &TransferOptions{}
+	}
+	destDir = path.Clean("/" + destDir)
+	if destDir == "/" {
+		return fmt.Errorf("upload dir error: destination must not be the root directory")
+	}
+
+	var total int64
+	err := filepath.WalkDir(localDir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("upload dir %s error: %v", localDir, err)
+	}
+
+	// The archive holds destDir itself, so tar creates it below its parent
+	counter := newTransferCounter(opts, total)
+	sums := map[string]string{}
+	pr, pw := io.Pipe()
+	written := make(chan error, 1)
+	go func() {
+		err := writeDirTar(localDir, destDir, pw, counter, sums)
+		pw.CloseWithError(err)
+		written <- err
+	}()
+	_, err = p.runFile([]string{"tar"}, pr, nil, func(root string) []string {
+		return []string{"tar", "-xpf", "-", "-C", inRoot(root, path.Dir(destDir))}
+	})
+	// Unblocks the writer when tar ended early
+	pr.CloseWithError(errTransferEnded)
+	if werr := <-written; werr != nil && !errors.Is(werr, errTransferEnded) {
+		return fmt.Errorf("upload dir %s error: %v", localDir, werr)
+	}
+	if err != nil {
+		return fmt.Errorf("upload dir %s error: %w", localDir, err)
+	}
+
+	if opts.NoVerify {
+		return nil
+	}
+	return p.verifyChecksums(destDir, sums, false)
+}
+
+// writeDirTar writes localDir as a tar of destDir to w, recording the checksum of each file by its path in the container
+func writeDirTar(localDir, destDir string, w io.Writer, counter *transferCounter, sums map[string]string) error {
+	tw := tar.NewWriter(w)
+	prefix := path.Base(destDir)
+	err := filepath.WalkDir(localDir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localDir, file)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		case !d.IsDir() && !d.Type().IsRegular():
+			klog.V(6).Infof("UploadDir skips %s, it is not a file, directory or link", file)
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(prefix, filepath.ToSlash(rel))
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		// Owned by the user extracting the archive, not by the local user
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		counter.start(filepath.ToSlash(rel))
+		sum := sha256.New()
+		if _, err := io.Copy(tw, io.TeeReader(f, io.MultiWriter(sum, counter))); err != nil {
+			return err
+		}
+		sums[path.Join(path.Dir(destDir), hdr.Name)] = hex.EncodeToString(sum.Sum(nil))
+		counter.done()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// DownloadDir streams the directory srcDir in the container to the local directory localDir, which is created if missing
+// Permissions, modification times, hard links and symbolic links are kept, symbolic links pointing outside localDir are skipped.
+// The total size is unknown to the progress. It needs tar, and find and sha256sum to verify the checksums.
+// The FileHelper is used when they are missing.
+//
+// Example:
+// err := kom.DefaultCluster().Namespace("default").Name("java").Ctl().Pod().ContainerName("app").DownloadDir("/dumps", "./dumps", nil)
+func (p *pod) DownloadDir(srcDir, localDir string, opts *TransferOptions) error {
+	klog.V(6).Infof("DownloadDir %s to %s [%s/%s:%s]\n", srcDir, localDir, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+	if opts == nil {
+		opts = &TransferOptions{}
+	}
+	srcDir = path.Clean("/" + srcDir)
+	if err := os.MkdirAll(localDir, 0755); err != nil {
+		return fmt.Errorf("download dir error: %v", err)
+	}
+
+	counter := newTransferCounter(opts, 0)
+	sums := map[string]string{}
+	pr, pw := io.Pipe()
+	extracted := make(chan error, 1)
+	go func() {
+		err := extractDirTar(pr, srcDir, localDir, counter, sums)
+		if err == nil {
+			// Drains the padding after the end of the archive
+			_, err = io.Copy(io.Discard, pr)
+		}
+		// Unblocks tar when extracting ended early
+		pr.CloseWithError(err)
+		extracted <- err
+	}()
+	_, err := p.runFile([]string{"tar"}, nil, pw, func(root string) []string {
+		return []string{"tar", "cf", "-", "-C", inRoot(root, srcDir), "."}
+	})
+	pw.CloseWithError(err)
+	xerr := <-extracted
+	if err != nil && (xerr == nil || errors.Is(xerr, err)) {
+		return fmt.Errorf("download dir %s error: %w", srcDir, err)
+	}
+	if xerr != nil {
+		// Extracting failed first, e.g. the local disk is full
+		return fmt.Errorf("download dir %s error: %v", srcDir, xerr)
+	}
+
+	if opts.NoVerify {
+		return nil
+	}
+	return p.verifyChecksums(srcDir, sums, true)
+}
+
+// extractDirTar extracts a tar of srcDir into localDir, recording the checksum of each file by its path in the container
+func extractDirTar(r io.Reader, srcDir, localDir string, counter *transferCounter, sums map[string]string) error {
+	type dirMeta struct {
+		path string
+		hdr  *tar.Header
+	}
+	var dirs []dirMeta
+	// Entries are checked against the real directory, links created earlier may redirect later paths
+	root, err := filepath.EvalSymlinks(localDir)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read tar error: %w", err)
+		}
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("tar entry %s is outside of %s", hdr.Name, srcDir)
+		}
+		target, err := realInside(root, filepath.Join(root, filepath.FromSlash(name)), hdr.Typeflag != tar.TypeDir)
+		if err != nil {
+			return fmt.Errorf("tar entry %s error: %w", hdr.Name, err)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			// Modes and times are set last, a read-only directory would refuse its files
+			dirs = append(dirs, dirMeta{target, hdr})
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if fi, err := os.Lstat(target); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+				// Replaced like tar does, writing through it could leave localDir
+				_ = os.Remove(target)
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, hdr.FileInfo().Mode().Perm())
+			if err != nil {
+				return err
+			}
+			counter.start(name)
+			sum := sha256.New()
+			_, err = io.Copy(io.MultiWriter(f, sum, counter), tr)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return fmt.Errorf("write %s error: %v", target, err)
+			}
+			if err := applyMeta(target, hdr); err != nil {
+				return err
+			}
+			sums[path.Join(srcDir, name)] = hex.EncodeToString(sum.Sum(nil))
+			counter.done()
+		case tar.TypeLink:
+			// Later names of a hard linked file refer to the first one, which was already extracted
+			linkname := path.Clean(hdr.Linkname)
+			first, ok := sums[path.Join(srcDir, linkname)]
+			if !ok {
+				return fmt.Errorf("tar entry %s links to %s, which was not extracted", hdr.Name, hdr.Linkname)
+			}
+			src, err := realInside(root, filepath.Join(root, filepath.FromSlash(linkname)), false)
+			if err != nil {
+				return fmt.Errorf("tar entry %s error: %w", hdr.Name, err)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			_ = os.Remove(target)
+			if err := os.Link(src, target); err != nil {
+				return err
+			}
+			sums[path.Join(srcDir, name)] = first
+			counter.start(name)
+			counter.done()
+		case tar.TypeSymlink:
+			if !linkInside(root, target, hdr.Linkname) {
+				klog.Warningf("DownloadDir skips link %s to %s, it points outside of %s", name, hdr.Linkname, localDir)
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			_ = os.Remove(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		default:
+			klog.V(6).Infof("DownloadDir skips %s of type %c", name, hdr.Typeflag)
+		}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := applyMeta(dirs[i].path, dirs[i].hdr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyMeta sets the permissions and modification time of hdr on a written file or directory
+func applyMeta(target string, hdr *tar.Header) error {
+	if err := os.Chmod(target, hdr.FileInfo().Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+}
+
+// linkInside reports whether the link at target to linkname stays inside dir
+// target must already be a real path, see realInside.
+func linkInside(dir, target, linkname string) bool {
+	if filepath.IsAbs(linkname) {
+		return false
+	}
+	resolved, err := realInside(dir, filepath.Join(filepath.Dir(target), linkname), false)
+	return err == nil && isInside(dir, resolved)
+}
+
+// realInside resolves the symbolic links in p and fails unless the result stays inside root
+// With parentOnly, the last element is kept as is, so an existing link at p is not followed.
+// Missing trailing elements are kept, they are created as plain files or directories.
+func realInside(root, p string, parentOnly bool) (string, error) {
+	existing, rest := p, ""
+	if parentOnly {
+		existing, rest = filepath.Dir(p), filepath.Base(p)
+	}
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", err
+	}
+	resolved = filepath.Join(resolved, rest)
+	if !isInside(root, resolved) {
+		return "", fmt.Errorf("%s is outside of %s", p, root)
+	}
+	return resolved, nil
+}
+
+// isInside reports whether p is root or below it
+func isInside(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// verifyChecksums compares the sha256 of the transferred files with the files below dir in the container
+// With all, every file in the container must have been transferred.
+func (p *pod) verifyChecksums(dir string, sums map[string]string, all bool) error {
+	remote, err := p.sha256Sums(dir)
+	if err != nil {
+		return fmt.Errorf("verify checksum of %s error: %w", dir, err)
+	}
+	for file, sum := range sums {
+		file = path.Clean("/" + file)
+		if remote[file] != sum {
+			return fmt.Errorf("checksum of %s does not match, sent %s, container has %q", file, sum, remote[file])
+		}
+	}
+	if all {
+		for file := range remote {
+			if _, ok := sums[file]; !ok {
+				return fmt.Errorf("file %s in the container was not transferred", file)
+			}
+		}
+	}
+	return nil
+}
+
+// sha256Sums returns the sha256 of every file at or below path in the container, by path
+func (p *pod) sha256Sums(filePath string) (map[string]string, error) {
+	var root string
+	out, err := p.runFile([]string{"find", "sha256sum"}, nil, nil, func(r string) []string {
+		root = r
+		// -H follows path itself when it is a symbolic link, like dd and tar do
+		return []string{"find", "-H", inRoot(r, filePath), "-type", "f", "-exec", "sha256sum", "{}", ";"}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sums := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSuffix(string(out), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		// Names with a backslash or newline are escaped and the line starts with a backslash
+		escaped := strings.HasPrefix(line, "\\")
+		sum, file, ok := strings.Cut(strings.TrimPrefix(line, "\\"), "  ")
+		if !ok {
+			return nil, fmt.Errorf("unexpected sha256sum output %q", line)
+		}
+		if escaped {
+			file = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\r`, "\r").Replace(file)
+		}
+		sums[path.Clean("/"+strings.TrimPrefix(file, root))] = sum
+	}
+	return sums, nil
+}
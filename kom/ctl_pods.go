@@ -0,0 +1,193 @@
+package kom
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const defaultPodsConcurrency = 10
+
+// pods is a set of pods selected by a label selector, a Where condition or a workload
+type pods struct {
+	kubectl       *Kubectl
+	list          func() ([]*v1.Pod, error)
+	containers    []string
+	allContainers bool
+	concurrency   int
+	command       string
+	args          []string
+	timeout       time.Duration
+	maxOutput     int
+}
+
+// PodExecResult is the outcome of a command in one container of a pod set
+type PodExecResult struct {
+	Namespace string      `json:"namespace"`
+	Pod       string      `json:"pod"`
+	Container string      `json:"container"`
+	Result    *ExecResult `json:"result,omitempty"`
+	Error     error       `json:"-"` // The command could not be run in this container
+}
+
+// Pods selects the pods matching the label selector and Where conditions of the chain
+//
+// Example:
+// kom.DefaultCluster().Namespace("default").WithLabelSelector("app=nginx").Ctl().Pods().Command("date").Run()
+// kom.DefaultCluster().Namespace("default").Where("status.phase='Running'").Ctl().Pods().Command("date").Run()
+func (c *ctl) Pods() *pods {
+	k := c.kubectl
+	return newPods(k, func() ([]*v1.Pod, error) {
+		var list []*v1.Pod
+		err := k.getInstance().Resource(&v1.Pod{}).List(&list).Error
+		return list, err
+	})
+}
+
+func newPods(k *Kubectl, list func() ([]*v1.Pod, error)) *pods {
+	return &pods{kubectl: k, list: list, concurrency: defaultPodsConcurrency}
+}
+
+// List returns the selected pods
+func (p *pods) List() ([]*v1.Pod, error) {
+	return p.list()
+}
+
+// ContainerName runs in the named containers, by default the default container of each pod is used
+func (p *pods) ContainerName(names ...string) *pods {
+	p.containers = names
+	return p
+}
+
+// AllContainers runs in every container of each pod
+func (p *pods) AllContainers() *pods {
+	p.allContainers = true
+	return p
+}
+
+// Concurrency limits how many containers are worked on at the same time, 10 by default
+func (p *pods) Concurrency(n int) *pods {
+	if n > 0 {
+		p.concurrency = n
+	}
+	return p
+}
+
+func (p *pods) Command(command string, args ...string) *pods {
+	p.command = command
+	p.args = args
+	return p
+}
+
+// Timeout stops the command in a container when it runs longer than d
+func (p *pods) Timeout(d time.Duration) *pods {
+	p.timeout = d
+	return p
+}
+
+// MaxOutput keeps at most limit bytes of stdout and of stderr per container
+func (p *pods) MaxOutput(limit int) *pods {
+	p.maxOutput = limit
+	return p
+}
+
+// Run executes the command in every selected container and returns one result per container, ordered by pod
+// The error is only set when the pods could not be listed, failures of single containers are in PodExecResult.Error.
+//
+// Example:
+// results, err := kom.DefaultCluster().Namespace("default").Name("nginx").Ctl().Deployment().Pods().Command("nginx", "-t").Run()
+func (p *pods) Run() ([]*PodExecResult, error) {
+	if p.command == "" {
+		return nil, fmt.Errorf("Please call Command() method to set command")
+	}
+	list, err := p.list()
+	if err != nil {
+		return nil, fmt.Errorf("list pods error %v", err)
+	}
+
+	var results []*PodExecResult
+	for _, item := range list {
+		containers, err := p.targetContainers(item)
+		if err != nil {
+			results = append(results, &PodExecResult{Namespace: item.Namespace, Pod: item.Name, Error: err})
+			continue
+		}
+		for _, c := range containers {
+			results = append(results, &PodExecResult{Namespace: item.Namespace, Pod: item.Name, Container: c})
+		}
+	}
+
+	sem := make(chan struct{}, p.concurrency)
+	var wg sync.WaitGroup
+	for _, r := range results {
+		if r.Error != nil {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(r *PodExecResult) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			r.Result, r.Error = p.kubectl.newInstance().
+				WithContext(p.kubectl.Statement.Context).
+				Resource(&v1.Pod{}).
+				Namespace(r.Namespace).
+				Name(r.Pod).
+				Ctl().Pod().
+				ContainerName(r.Container).
+				Command(p.command, p.args...).
+				Timeout(p.timeout).
+				MaxOutput(p.maxOutput).
+				Run()
+		}(r)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// targetContainers returns the containers of item to work on
+func (p *pods) targetContainers(item *v1.Pod) ([]string, error) {
+	if item.Status.Phase != v1.PodRunning {
+		return nil, fmt.Errorf("pod %s/%s is %s, not Running", item.Namespace, item.Name, item.Status.Phase)
+	}
+	return p.containersOf(item)
+}
+
+// containersOf returns the named containers, all containers, or the default container of item
+func (p *pods) containersOf(item *v1.Pod) ([]string, error) {
+	if p.allContainers {
+		var names []string
+		for _, c := range item.Spec.Containers {
+			names = append(names, c.Name)
+		}
+		return names, nil
+	}
+	if len(p.containers) > 0 {
+		for _, name := range p.containers {
+			if !hasContainer(item, name) {
+				return nil, fmt.Errorf("container %s not found in pod %s/%s", name, item.Namespace, item.Name)
+			}
+		}
+		return p.containers, nil
+	}
+	if name := item.Annotations["kubectl.kubernetes.io/default-container"]; name != "" && hasContainer(item, name) {
+		return []string{name}, nil
+	}
+	if len(item.Spec.Containers) == 0 {
+		return nil, fmt.Errorf("pod %s/%s has no containers", item.Namespace, item.Name)
+	}
+	return []string{item.Spec.Containers[0].Name}, nil
+}
+
+func hasContainer(item *v1.Pod, name string) bool {
+	for _, c := range item.Spec.Containers {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
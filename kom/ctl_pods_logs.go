@@ -0,0 +1,191 @@
+package kom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// podsLogsRefreshInterval is how often followed pod sets are listed again to pick up new pods
+var podsLogsRefreshInterval = 2 * time.Second
+
+// LogLine is a log line of one container in a pod set
+type LogLine struct {
+	Namespace string
+	Pod       string
+	Container string
+	Line      []byte
+}
+
+// String returns the line prefixed with pod/container
+func (l *LogLine) String() string {
+	return fmt.Sprintf("[%s/%s] %s", l.Pod, l.Container, l.Line)
+}
+
+// Logs merges the log lines of the selected containers, fn is never called concurrently
+// The containers are chosen like for Run. With Follow, new pods are picked up as they start,
+// and Logs ends when the context ends or fn returns an error. Return ErrStopLogs from fn to stop without an error.
+// Without Follow, the lines of the other containers are still delivered when a stream fails, and the errors are returned joined.
+//
+// Example:
+//
+//	err := kom.DefaultCluster().WithContext(ctx).Namespace("default").Name("nginx").Ctl().Deployment().
+//		Logs(kom.NewLogOptions().Follow().Tail(10), func(line *kom.LogLine) error {
+//			fmt.Println(line)
+//			return nil
+//		})
+func (p *pods) Logs(opts *LogOptions, fn func(line *LogLine) error) error {
+	if opts == nil {
+		opts = NewLogOptions()
+	}
+	base := p.kubectl.Statement.Context
+	if base == nil {
+		base = context.Background()
+	}
+	ctx, cancel := context.WithCancel(base)
+	defer cancel()
+
+	// Streams are keyed by pod UID and name, then container
+	type streamKey struct{ pod, container string }
+	var (
+		mu         sync.Mutex // Serializes fn and guards the fields below
+		fnErr      error
+		streamErrs []error                // Errors of single streams, returned when not following
+		started    = map[streamKey]bool{} // Only retried when they never connected
+		wg         sync.WaitGroup
+	)
+	emit := func(line *LogLine) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if fnErr != nil {
+			return fnErr
+		}
+		if err := fn(line); err != nil {
+			fnErr = err
+			cancel()
+			return err
+		}
+		return nil
+	}
+
+	follow := opts.opts.Follow
+	for {
+		list, err := p.list()
+		if err != nil && !follow {
+			return fmt.Errorf("list pods error %v", err)
+		}
+		if err != nil {
+			klog.V(6).Infof("list pods for logs error %v", err)
+		} else {
+			// Forget the streams of pods that are gone, so following a long time does not grow started
+			present := map[string]bool{}
+			for _, item := range list {
+				present[string(item.UID)+"/"+item.Name] = true
+			}
+			mu.Lock()
+			for key := range started {
+				if !present[key.pod] {
+					delete(started, key)
+				}
+			}
+			mu.Unlock()
+		}
+		for _, item := range list {
+			if item.Status.Phase == v1.PodPending || item.Status.Phase == v1.PodUnknown {
+				// Picked up later once running
+				continue
+			}
+			containers, err := p.containersOf(item)
+			if err != nil {
+				klog.V(6).Infof("logs of pod %s/%s skipped: %v", item.Namespace, item.Name, err)
+				continue
+			}
+			for _, c := range containers {
+				key := streamKey{pod: string(item.UID) + "/" + item.Name, container: c}
+				mu.Lock()
+				seen := started[key]
+				started[key] = true
+				mu.Unlock()
+				if seen {
+					continue
+				}
+				wg.Add(1)
+				go func(key streamKey, ns, name, container string) {
+					defer wg.Done()
+					err := p.kubectl.newInstance().
+						WithContext(ctx).
+						Resource(&v1.Pod{}).
+						Namespace(ns).
+						Name(name).
+						Ctl().Pod().
+						ContainerName(container).
+						StreamLogs(opts, func(line []byte) error {
+							return emit(&LogLine{Namespace: ns, Pod: name, Container: container, Line: line})
+						}).Error
+					if err != nil && ctx.Err() == nil {
+						klog.V(6).Infof("logs of %s/%s:%s ended: %v", ns, name, container, err)
+						if !follow {
+							mu.Lock()
+							streamErrs = append(streamErrs, fmt.Errorf("logs of %s/%s:%s error: %w", ns, name, container, err))
+							mu.Unlock()
+						}
+					}
+					var notConnected *logsNotConnectedError
+					if errors.As(err, &notConnected) {
+						// Never streamed, e.g. the container is still starting, the next refresh retries
+						mu.Lock()
+						delete(started, key)
+						mu.Unlock()
+					}
+				}(key, item.Namespace, item.Name, c)
+			}
+		}
+		if !follow {
+			break
+		}
+
+		timer := time.NewTimer(podsLogsRefreshInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	wg.Wait()
+
+	if errors.Is(fnErr, ErrStopLogs) {
+		return nil
+	}
+	if fnErr != nil {
+		return fnErr
+	}
+	return errors.Join(streamErrs...)
+}
+
+// Logs merges the log lines of the pods managed by the Deployment, see pods.Logs
+func (d *deploy) Logs(opts *LogOptions, fn func(line *LogLine) error) error {
+	return d.Pods().Logs(opts, fn)
+}
+
+// Logs merges the log lines of the pods managed by the StatefulSet, see pods.Logs
+func (s *statefulSet) Logs(opts *LogOptions, fn func(line *LogLine) error) error {
+	return s.Pods().Logs(opts, fn)
+}
+
+// Logs merges the log lines of the pods managed by the DaemonSet, see pods.Logs
+func (d *daemonSet) Logs(opts *LogOptions, fn func(line *LogLine) error) error {
+	return d.Pods().Logs(opts, fn)
+}
+
+// Logs merges the log lines of the pods managed by the ReplicaSet, see pods.Logs
+func (r *replicaSet) Logs(opts *LogOptions, fn func(line *LogLine) error) error {
+	return r.Pods().Logs(opts, fn)
+}
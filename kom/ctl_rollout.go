@@ -48,6 +48,10 @@ func (d *rollout) checkResourceKind(kind string, supportedKinds []string) error
 }
 
 func (d *rollout) Restart() error {
+	return d.kubectl.traceHelper("kom.rollout.restart", d.restart)
+}
+
+func (d *rollout) restart() error {
 
 	kind := d.kubectl.Statement.GVK.Kind
 	d.logInfo("Restart")
@@ -193,7 +197,15 @@ type ContainerInfo struct {
 	Image string `json:"image,omitempty"`
 }
 
-func (d *rollout) History() ([]RolloutHistory, error) {
+func (d *rollout) History() (history []RolloutHistory, err error) {
+	err = d.kubectl.traceHelper("kom.rollout.history", func() error {
+		history, err = d.history()
+		return err
+	})
+	return history, err
+}
+
+func (d *rollout) history() ([]RolloutHistory, error) {
 	kind := d.kubectl.Statement.GVK.Kind
 	name := d.kubectl.Statement.Name
 	ns := d.kubectl.Statement.Namespace
@@ -380,7 +392,15 @@ func (d *rollout) filterByOwner(versionList []*v1.ControllerRevision, kind strin
 	})
 	return versionList
 }
-func (d *rollout) Undo(toVersions ...int) (string, error) {
+func (d *rollout) Undo(toVersions ...int) (result string, err error) {
+	err = d.kubectl.traceHelper("kom.rollout.undo", func() error {
+		result, err = d.undo(toVersions...)
+		return err
+	})
+	return result, err
+}
+
+func (d *rollout) undo(toVersions ...int) (string, error) {
 	kind := d.kubectl.Statement.GVK.Kind
 	name := d.kubectl.Statement.Name
 	namespace := d.kubectl.Statement.Namespace
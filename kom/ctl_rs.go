@@ -25,6 +25,10 @@ func (r *replicaSet) Restore() error {
 	return r.kubectl.Ctl().Scaler().Restore()
 }
 
+// Pods selects the pods managed by the ReplicaSet, e.g. to run a command in every replica
+func (r *replicaSet) Pods() *pods {
+	return newPods(r.kubectl, r.ManagedPods)
+}
 func (r *replicaSet) ManagedPods() ([]*corev1.Pod, error) {
 	// First find the ReplicaSet
 	var rs v1.ReplicaSet
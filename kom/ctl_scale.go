@@ -14,6 +14,12 @@ type scale struct {
 }
 
 func (s *scale) Scale(replicas int32) error {
+	return s.kubectl.traceHelper("kom.scale", func() error {
+		return s.scale(replicas)
+	})
+}
+
+func (s *scale) scale(replicas int32) error {
 
 	kind := s.kubectl.Statement.GVK.Kind
 	klog.V(8).Infof("scale Kind=%s", kind)
@@ -45,6 +51,10 @@ func (s *scale) Scale(replicas int32) error {
 // Before stopping, records the current number of replicas in the deployment's annotation
 // kom.restore.replicas
 func (s *scale) Stop() error {
+	return s.kubectl.traceHelper("kom.scale.stop", s.stop)
+}
+
+func (s *scale) stop() error {
 	kind := s.kubectl.Statement.GVK.Kind
 	if !isSupportedKind(kind, []string{"Deployment", "StatefulSet", "ReplicationController", "ReplicaSet"}) {
 		s.kubectl.Error = fmt.Errorf("%s %s/%s Scale is not supported", kind, s.kubectl.Statement.Namespace, s.kubectl.Statement.Name)
@@ -92,6 +102,10 @@ func (s *scale) Stop() error {
 // Sets the value of kom.restore.replicas as the deployment's replicas
 // If not found, sets it to 1
 func (s *scale) Restore() error {
+	return s.kubectl.traceHelper("kom.scale.restore", s.restore)
+}
+
+func (s *scale) restore() error {
 	kind := s.kubectl.Statement.GVK.Kind
 	if !isSupportedKind(kind, []string{"Deployment", "StatefulSet", "ReplicationController", "ReplicaSet"}) {
 		s.kubectl.Error = fmt.Errorf("%s %s/%s Scale is not supported", kind, s.kubectl.Statement.Namespace, s.kubectl.Statement.Name)
@@ -26,6 +26,10 @@ func (s *statefulSet) Restore() error {
 	return s.kubectl.Ctl().Scaler().Restore()
 }
 
+// Pods selects the pods managed by the StatefulSet, e.g. to run a command in every replica
+func (s *statefulSet) Pods() *pods {
+	return newPods(s.kubectl, s.ManagedPods)
+}
 func (s *statefulSet) ManagedPods() ([]*corev1.Pod, error) {
 	// First find the StatefulSet
 	var sts v1.StatefulSet
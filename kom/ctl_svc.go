@@ -0,0 +1,152 @@
+package kom
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+type service struct {
+	kubectl *Kubectl
+}
+
+// PortForward forwards localPort on localhost to servicePort of the service
+// Like kubectl port-forward svc/name, a ready pod behind the service is picked and the service targetPort is forwarded.
+//
+// Example:
+// fw, err := kom.DefaultCluster().Namespace("default").Name("nginx").Ctl().Service().PortForward(8080, 80)
+// defer fw.Stop()
+func (s *service) PortForward(localPort, servicePort int, addresses ...string) (*PortForwarder, error) {
+	podName, podPort, err := s.resolvePort(servicePort)
+	if err != nil {
+		return nil, err
+	}
+	p := &pod{
+		kubectl: s.kubectl.newInstance().
+			WithContext(s.kubectl.Statement.Context).
+			Resource(&v1.Pod{}).
+			Namespace(s.kubectl.Statement.Namespace).
+			Name(podName),
+	}
+	return p.PortForward(localPort, podPort, addresses...)
+}
+
+// resolvePort finds a ready pod of the service and the pod port servicePort is routed to
+func (s *service) resolvePort(servicePort int) (string, int, error) {
+	ns := s.kubectl.Statement.Namespace
+	name := s.kubectl.Statement.Name
+
+	var svc *v1.Service
+	err := s.kubectl.newInstance().
+		WithContext(s.kubectl.Statement.Context).
+		Resource(&v1.Service{}).
+		Namespace(ns).
+		Name(name).
+		Get(&svc).Error
+	if err != nil {
+		return "", 0, fmt.Errorf("get service %s/%s error %v", ns, name, err)
+	}
+
+	var svcPort *v1.ServicePort
+	for i := range svc.Spec.Ports {
+		if int(svc.Spec.Ports[i].Port) == servicePort {
+			svcPort = &svc.Spec.Ports[i]
+			break
+		}
+	}
+	if svcPort == nil {
+		return "", 0, fmt.Errorf("service %s/%s does not have port %d", ns, name, servicePort)
+	}
+
+	// Endpoints have the same name as the service and hold the resolved target ports
+	endpoints, err := linkedEndpoints(s.kubectl, []string{name})
+	if err == nil {
+		for _, item := range endpoints {
+			if podName, port, ok := podFromEndpoints(item, svcPort); ok {
+				return podName, port, nil
+			}
+		}
+	}
+
+	// Services without endpoints, e.g. while no pod is ready, fall back to the selector
+	if len(svc.Spec.Selector) == 0 {
+		return "", 0, fmt.Errorf("service %s/%s has no ready endpoints and no selector", ns, name)
+	}
+	pods, err := s.managedPods(svc)
+	if err != nil {
+		return "", 0, fmt.Errorf("list pods of service %s/%s error %v", ns, name, err)
+	}
+	for _, item := range pods {
+		if item.Status.Phase != v1.PodRunning {
+			continue
+		}
+		target := svcPort.TargetPort
+		if target.Type == intstr.Int && target.IntVal == 0 {
+			// targetPort defaults to port
+			target = intstr.FromInt32(svcPort.Port)
+		}
+		if port, ok := containerPort(item, target); ok {
+			return item.Name, port, nil
+		}
+	}
+	return "", 0, fmt.Errorf("service %s/%s has no running pod for port %d", ns, name, servicePort)
+}
+
+// managedPods returns the pods selected by svc, matched like Pod LinkedService does
+func (s *service) managedPods(svc *v1.Service) ([]*v1.Pod, error) {
+	var pods []*v1.Pod
+	err := s.kubectl.newInstance().
+		WithContext(s.kubectl.Statement.Context).
+		Resource(&v1.Pod{}).
+		Namespace(svc.Namespace).
+		RemoveManagedFields().
+		List(&pods).Error
+	if err != nil {
+		return nil, err
+	}
+	var selected []*v1.Pod
+	for _, item := range pods {
+		if serviceSelects(svc, item) {
+			selected = append(selected, item)
+		}
+	}
+	return selected, nil
+}
+
+// podFromEndpoints picks the first ready pod address and the port matching the service port
+func podFromEndpoints(endpoints *v1.Endpoints, svcPort *v1.ServicePort) (string, int, bool) {
+	for _, subset := range endpoints.Subsets {
+		port := 0
+		for _, p := range subset.Ports {
+			if p.Name == svcPort.Name && p.Protocol == svcPort.Protocol {
+				port = int(p.Port)
+				break
+			}
+		}
+		if port == 0 {
+			continue
+		}
+		for _, addr := range subset.Addresses {
+			if addr.TargetRef != nil && addr.TargetRef.Kind == "Pod" {
+				return addr.TargetRef.Name, port, true
+			}
+		}
+	}
+	return "", 0, false
+}
+
+// containerPort resolves a numeric or named targetPort on the pod
+func containerPort(item *v1.Pod, target intstr.IntOrString) (int, bool) {
+	if target.Type == intstr.Int {
+		return target.IntValue(), target.IntValue() > 0
+	}
+	for _, c := range item.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.Name == target.StrVal {
+				return int(p.ContainerPort), true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,367 @@
+package kom
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Diff status of a single resource
+const (
+	DiffIdentical = "identical"  // Same on both sides
+	DiffChanged   = "changed"    // Exists on both sides with different fields
+	DiffOnlyLeft  = "only-left"  // Exists only in the left cluster
+	DiffOnlyRight = "only-right" // Exists only in the right cluster
+)
+
+// Field change types
+const (
+	FieldAdded   = "added"   // Only present on the right
+	FieldRemoved = "removed" // Only present on the left
+	FieldChanged = "changed" // Present on both sides with different values
+)
+
+// defaultDiffKinds are compared when no kinds are given
+var defaultDiffKinds = []string{
+	"Deployment", "StatefulSet", "DaemonSet", "CronJob",
+	"Service", "Ingress", "ConfigMap", "Secret",
+	"ServiceAccount", "Role", "RoleBinding",
+	"PersistentVolumeClaim", "HorizontalPodAutoscaler",
+}
+
+// serverPopulatedFields are set by the API server and never compared
+var serverPopulatedFields = [][]string{
+	{"status"},
+	{"metadata", "managedFields"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "uid"},
+	{"metadata", "generation"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "deletionTimestamp"},
+	{"metadata", "deletionGracePeriodSeconds"},
+	{"metadata", "selfLink"},
+	{"metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration"},
+	{"metadata", "annotations", "deployment.kubernetes.io/revision"},
+	{"spec", "clusterIP"},
+	{"spec", "clusterIPs"},
+}
+
+// FieldDiff is a single field-level difference
+type FieldDiff struct {
+	Path  string      `json:"path"` // e.g. spec.template.spec.containers[0].image
+	Type  string      `json:"type"` // added, removed or changed
+	Left  interface{} `json:"left,omitempty"`
+	Right interface{} `json:"right,omitempty"`
+}
+
+// ResourceDiff is the comparison of one resource between two clusters
+type ResourceDiff struct {
+	GVK       schema.GroupVersionKind `json:"gvk"`
+	Namespace string                  `json:"namespace,omitempty"`
+	Name      string                  `json:"name"`
+	Status    string                  `json:"status"` // identical, changed, only-left or only-right
+	Fields    []FieldDiff             `json:"fields,omitempty"`
+}
+
+// DiffResult is the comparison of resources between two clusters
+type DiffResult struct {
+	Left      string          `json:"left"`  // Left cluster ID
+	Right     string          `json:"right"` // Right cluster ID
+	Resources []*ResourceDiff `json:"resources"`
+}
+
+// Changed returns every resource that is not identical on both sides
+func (r *DiffResult) Changed() []*ResourceDiff {
+	var changed []*ResourceDiff
+	for _, res := range r.Resources {
+		if res.Status != DiffIdentical {
+			changed = append(changed, res)
+		}
+	}
+	return changed
+}
+
+type differ struct {
+	left      *Kubectl
+	right     *Kubectl
+	namespace string
+	name      string
+	kinds     []string
+	ignore    [][]string
+}
+
+// Diff compares resources between two clusters
+// Server-populated fields like status, managedFields, resourceVersion and uid are ignored,
+// and Secret values are compared by hash so they never show up in the result.
+//
+// Example:
+//
+//	result, err := kom.Diff(kom.Cluster("staging"), kom.Cluster("prod")).
+//		Namespace("app").
+//		Kinds("Deployment", "ConfigMap").
+//		Do()
+//
+// The left side may also carry the resource to compare:
+//
+//	kom.Diff(kom.Cluster("staging").Resource(&v1.Deployment{}).Namespace("app").Name("web"), kom.Cluster("prod")).Do()
+func Diff(left, right *Kubectl) *differ {
+	d := &differ{left: left, right: right}
+	if left != nil && left.Statement != nil {
+		d.namespace = left.Statement.Namespace
+		d.name = left.Statement.Name
+		if gvk := left.Statement.GVK; !gvk.Empty() {
+			d.kinds = []string{gvk.Kind}
+		}
+	}
+	return d
+}
+
+// Namespace sets the namespace to compare
+func (d *differ) Namespace(ns string) *differ {
+	d.namespace = ns
+	return d
+}
+
+// Name limits the comparison to a single resource name
+func (d *differ) Name(name string) *differ {
+	d.name = name
+	return d
+}
+
+// Kinds sets the resource kinds to compare, by kind, plural or short name
+func (d *differ) Kinds(kinds ...string) *differ {
+	d.kinds = kinds
+	return d
+}
+
+// IgnoreFields adds dotted field paths that are not compared, e.g. "spec.replicas"
+func (d *differ) IgnoreFields(paths ...string) *differ {
+	for _, p := range paths {
+		d.ignore = append(d.ignore, strings.Split(p, "."))
+	}
+	return d
+}
+
+// Do runs the comparison
+func (d *differ) Do() (*DiffResult, error) {
+	if d.left == nil || d.right == nil {
+		return nil, fmt.Errorf("both clusters must be registered to diff")
+	}
+	kinds := d.kinds
+	if len(kinds) == 0 {
+		kinds = defaultDiffKinds
+	}
+	result := &DiffResult{Left: d.left.ID, Right: d.right.ID}
+	for _, kind := range kinds {
+		leftItems, leftGVK, err := d.listSide(d.left, kind)
+		if err != nil {
+			return nil, fmt.Errorf("list %s in cluster %s error: %v", kind, d.left.ID, err)
+		}
+		rightItems, rightGVK, err := d.listSide(d.right, kind)
+		if err != nil {
+			return nil, fmt.Errorf("list %s in cluster %s error: %v", kind, d.right.ID, err)
+		}
+		gvk := leftGVK
+		if gvk.Empty() {
+			gvk = rightGVK
+		}
+		result.Resources = append(result.Resources, d.compare(gvk, leftItems, rightItems)...)
+	}
+	return result, nil
+}
+
+// listSide lists the resources of one kind in one cluster, keyed by namespace/name
+// A kind unknown to the cluster is treated as having no resources
+func (d *differ) listSide(k *Kubectl, kind string) (map[string]*unstructured.Unstructured, schema.GroupVersionKind, error) {
+	items := map[string]*unstructured.Unstructured{}
+	gvk := k.Tools().FindGVKByTableNameInApiResources(kind)
+	if gvk == nil {
+		gvk = k.Tools().FindGVKByTableNameInCRDList(kind)
+	}
+	if gvk == nil {
+		return items, schema.GroupVersionKind{}, nil
+	}
+
+	tx := k.newInstance().GVK(gvk.Group, gvk.Version, gvk.Kind).Namespace(d.namespace)
+	var list []unstructured.Unstructured
+	if d.name != "" {
+		var item unstructured.Unstructured
+		err := tx.Name(d.name).Get(&item).Error
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return items, *gvk, nil
+			}
+			return nil, *gvk, err
+		}
+		list = append(list, item)
+	} else if err := tx.List(&list).Error; err != nil {
+		return nil, *gvk, err
+	}
+
+	for i := range list {
+		item := &list[i]
+		items[fmt.Sprintf("%s/%s", item.GetNamespace(), item.GetName())] = item
+	}
+	return items, *gvk, nil
+}
+
+func (d *differ) compare(gvk schema.GroupVersionKind, left, right map[string]*unstructured.Unstructured) []*ResourceDiff {
+	keys := map[string]struct{}{}
+	for key := range left {
+		keys[key] = struct{}{}
+	}
+	for key := range right {
+		keys[key] = struct{}{}
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var diffs []*ResourceDiff
+	for _, key := range sorted {
+		l, r := left[key], right[key]
+		res := &ResourceDiff{GVK: gvk}
+		switch {
+		case r == nil:
+			res.Namespace, res.Name, res.Status = l.GetNamespace(), l.GetName(), DiffOnlyLeft
+		case l == nil:
+			res.Namespace, res.Name, res.Status = r.GetNamespace(), r.GetName(), DiffOnlyRight
+		default:
+			res.Namespace, res.Name = l.GetNamespace(), l.GetName()
+			res.Fields = diffObjects(l, r, d.ignore)
+			res.Status = DiffIdentical
+			if len(res.Fields) > 0 {
+				res.Status = DiffChanged
+			}
+		}
+		diffs = append(diffs, res)
+	}
+	return diffs
+}
+
+// DiffObjects returns the field-level differences between two objects
+// Server-populated fields are ignored and Secret values are compared by hash
+func DiffObjects(left, right *unstructured.Unstructured) []FieldDiff {
+	return diffObjects(left, right, nil)
+}
+
+func diffObjects(left, right *unstructured.Unstructured, ignore [][]string) []FieldDiff {
+	var diffs []FieldDiff
+	compareValues("", normalizeForDiff(left, ignore), normalizeForDiff(right, ignore), &diffs)
+	return diffs
+}
+
+// normalizeForDiff returns a copy without server-populated and ignored fields
+func normalizeForDiff(obj *unstructured.Unstructured, ignore [][]string) map[string]interface{} {
+	if obj == nil {
+		return map[string]interface{}{}
+	}
+	cp := obj.DeepCopy()
+	for _, path := range serverPopulatedFields {
+		unstructured.RemoveNestedField(cp.Object, path...)
+	}
+	for _, path := range ignore {
+		unstructured.RemoveNestedField(cp.Object, path...)
+	}
+	// Owner UIDs differ in every cluster
+	if refs, found, _ := unstructured.NestedSlice(cp.Object, "metadata", "ownerReferences"); found {
+		for _, ref := range refs {
+			if m, ok := ref.(map[string]interface{}); ok {
+				delete(m, "uid")
+			}
+		}
+		_ = unstructured.SetNestedSlice(cp.Object, refs, "metadata", "ownerReferences")
+	}
+	if cp.GetKind() == "Secret" {
+		for _, field := range []string{"data", "stringData"} {
+			if data, found, _ := unstructured.NestedMap(cp.Object, field); found {
+				for k, v := range data {
+					data[k] = fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(fmt.Sprint(v))))
+				}
+				_ = unstructured.SetNestedMap(cp.Object, data, field)
+			}
+		}
+	}
+	// Drop metadata maps emptied by the removals above
+	for _, field := range []string{"annotations", "labels"} {
+		if m, found, _ := unstructured.NestedMap(cp.Object, "metadata", field); found && len(m) == 0 {
+			unstructured.RemoveNestedField(cp.Object, "metadata", field)
+		}
+	}
+	return cp.Object
+}
+
+func compareValues(path string, left, right interface{}, diffs *[]FieldDiff) {
+	switch l := left.(type) {
+	case map[string]interface{}:
+		r, ok := right.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := map[string]struct{}{}
+		for k := range l {
+			keys[k] = struct{}{}
+		}
+		for k := range r {
+			keys[k] = struct{}{}
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			lv, lok := l[k]
+			rv, rok := r[k]
+			child := joinFieldPath(path, k)
+			switch {
+			case !rok:
+				*diffs = append(*diffs, FieldDiff{Path: child, Type: FieldRemoved, Left: lv})
+			case !lok:
+				*diffs = append(*diffs, FieldDiff{Path: child, Type: FieldAdded, Right: rv})
+			default:
+				compareValues(child, lv, rv, diffs)
+			}
+		}
+		return
+	case []interface{}:
+		r, ok := right.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(l) || i < len(r); i++ {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(r):
+				*diffs = append(*diffs, FieldDiff{Path: child, Type: FieldRemoved, Left: l[i]})
+			case i >= len(l):
+				*diffs = append(*diffs, FieldDiff{Path: child, Type: FieldAdded, Right: r[i]})
+			default:
+				compareValues(child, l[i], r[i], diffs)
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(left, right) {
+		*diffs = append(*diffs, FieldDiff{Path: path, Type: FieldChanged, Left: left, Right: right})
+	}
+}
+
+// joinFieldPath appends a key to a dotted path, keys containing dots are quoted
+func joinFieldPath(path, key string) string {
+	if strings.Contains(key, ".") {
+		key = fmt.Sprintf("%q", key)
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
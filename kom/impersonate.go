@@ -0,0 +1,140 @@
+package kom
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/weibaohui/kom/kom/describe"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// maxImpersonatedClients is how many identities keep their clients per cluster, the least recently used are dropped
+var maxImpersonatedClients = 256
+
+// impersonatedClient holds the clients built for a single impersonated identity
+type impersonatedClient struct {
+	key           string
+	config        *rest.Config
+	client        *kubernetes.Clientset
+	dynamicClient *dynamic.DynamicClient
+
+	describerOnce sync.Once
+	describerMap  map[schema.GroupKind]describe.ResourceDescriber
+}
+
+// AsUser runs the following operation as the given user and groups
+// The request is sent with impersonation headers, so the user's RBAC is enforced by the API server
+//
+// Example:
+// kom.DefaultCluster().AsUser("alice", "dev").Resource(&pod).Namespace("default").Name("nginx").Delete()
+func (k *Kubectl) AsUser(user string, groups ...string) *Kubectl {
+	return k.Impersonate(rest.ImpersonationConfig{
+		UserName: user,
+		Groups:   groups,
+	})
+}
+
+// Impersonate runs the following operation with the given impersonation config
+func (k *Kubectl) Impersonate(cfg rest.ImpersonationConfig) *Kubectl {
+	tx := k.getInstance()
+	if cfg.UserName == "" {
+		tx.Error = fmt.Errorf("impersonation requires a user name")
+		return tx
+	}
+	tx.Statement.Impersonation = &cfg
+	tx.Statement.impersonated = nil
+
+	cluster := tx.parentCluster()
+	if cluster == nil {
+		tx.Error = fmt.Errorf("cluster %s not found", tx.ID)
+		return tx
+	}
+	ic, err := cluster.impersonatedClientFor(cfg)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	tx.Statement.impersonated = ic
+	return tx
+}
+
+// Identity returns a stable key of the impersonated identity
+// Returns an empty string when the operation runs with the cluster's own identity
+func (k *Kubectl) Identity() string {
+	if k.Statement == nil || k.Statement.Impersonation == nil {
+		return ""
+	}
+	return impersonationKey(*k.Statement.Impersonation)
+}
+
+// impersonatedClientFor returns the cached clients for an identity, building them from the cluster's base config on first use
+// At most maxImpersonatedClients identities are kept, a dropped identity gets new clients on its next use.
+func (c *ClusterInst) impersonatedClientFor(cfg rest.ImpersonationConfig) (*impersonatedClient, error) {
+	key := impersonationKey(cfg)
+
+	c.impersonatedMu.Lock()
+	defer c.impersonatedMu.Unlock()
+
+	if e, ok := c.impersonatedClients[key]; ok {
+		c.impersonatedLRU.MoveToFront(e)
+		return e.Value.(*impersonatedClient), nil
+	}
+
+	config := rest.CopyConfig(c.Config)
+	config.Impersonate = cfg
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("impersonate %s on cluster %s error: %v", cfg.UserName, c.ID, err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("impersonate %s on cluster %s error: %v", cfg.UserName, c.ID, err)
+	}
+	ic := &impersonatedClient{
+		key:           key,
+		config:        config,
+		client:        client,
+		dynamicClient: dynamicClient,
+	}
+	if c.impersonatedClients == nil {
+		c.impersonatedClients = make(map[string]*list.Element)
+		c.impersonatedLRU = list.New()
+	}
+	c.impersonatedClients[key] = c.impersonatedLRU.PushFront(ic)
+	for c.impersonatedLRU.Len() > maxImpersonatedClients {
+		oldest := c.impersonatedLRU.Back()
+		c.impersonatedLRU.Remove(oldest)
+		delete(c.impersonatedClients, oldest.Value.(*impersonatedClient).key)
+	}
+	return ic, nil
+}
+
+// DescriberMap returns describers bound to the impersonated identity, built on first use
+func (ic *impersonatedClient) DescriberMap() map[schema.GroupKind]describe.ResourceDescriber {
+	ic.describerOnce.Do(func() {
+		ic.describerMap = describe.InitializeDescriberMap(ic.config)
+	})
+	return ic.describerMap
+}
+
+// impersonationKey builds a cache key for an identity, independent of group and extra ordering
+func impersonationKey(cfg rest.ImpersonationConfig) string {
+	groups := append([]string{}, cfg.Groups...)
+	sort.Strings(groups)
+
+	var extras []string
+	for k, v := range cfg.Extra {
+		values := append([]string{}, v...)
+		sort.Strings(values)
+		extras = append(extras, fmt.Sprintf("%s=%s", k, strings.Join(values, ",")))
+	}
+	sort.Strings(extras)
+
+	return fmt.Sprintf("user=%s;uid=%s;groups=%s;extra=%s", cfg.UserName, cfg.UID, strings.Join(groups, ","), strings.Join(extras, ";"))
+}
@@ -19,13 +19,13 @@ type Kubectl struct {
 }
 
 // Initialize kubectl
-func initKubectl(config *rest.Config, id string) *Kubectl {
+func initKubectl(config *rest.Config, id string, ctx context.Context) *Kubectl {
 	klog.V(2).Infof("k8s init server address: %s\n", config.Host)
 
 	k := &Kubectl{ID: id, clone: 1}
 
 	k.Statement = &Statement{
-		Context: context.Background(),
+		Context: ctx,
 		Kubectl: k,
 	}
 
@@ -37,8 +37,10 @@ func (k *Kubectl) newInstance() *Kubectl {
 	tx := &Kubectl{ID: k.ID, Error: k.Error}
 	// clone with new statement
 	tx.Statement = &Statement{
-		Kubectl: k.Statement.Kubectl,
-		Context: k.Statement.Context,
+		Kubectl:       k.Statement.Kubectl,
+		Context:       k.Statement.Context,
+		Impersonation: k.Statement.Impersonation,
+		impersonated:  k.Statement.impersonated,
 	}
 	return tx
 }
@@ -61,6 +63,10 @@ func (k *Kubectl) getInstance() *Kubectl {
 			CacheTTL:     k.Statement.CacheTTL,
 			Filter:       k.Statement.Filter,
 			ForceDelete:  k.Statement.ForceDelete,
+			Retry:        k.Statement.Retry,
+
+			Impersonation: k.Statement.Impersonation,
+			impersonated:  k.Statement.impersonated,
 		}
 		return tx
 	}
@@ -69,22 +75,52 @@ func (k *Kubectl) getInstance() *Kubectl {
 }
 func (k *Kubectl) Callback() *callbacks {
 	cluster := Clusters().GetClusterById(k.ID)
+	if cluster == nil || cluster.Closed() {
+		// Fail every operation instead of panicking once the cluster is gone
+		return unavailableCallbacks(k, errClusterUnavailable(k.ID))
+	}
 	return cluster.callbacks
 }
 func (k *Kubectl) RestConfig() *rest.Config {
+	if ic := k.Statement.impersonated; ic != nil {
+		return ic.config
+	}
 	cluster := Clusters().GetClusterById(k.ID)
+	if cluster == nil {
+		return unavailableConfig(k.ID)
+	}
 	return cluster.Config
 }
 func (k *Kubectl) Client() *kubernetes.Clientset {
+	if ic := k.Statement.impersonated; ic != nil {
+		return ic.client
+	}
 	cluster := Clusters().GetClusterById(k.ID)
+	if cluster == nil {
+		// Fail requests instead of panicking once the cluster is gone
+		client, _ := kubernetes.NewForConfig(unavailableConfig(k.ID))
+		return client
+	}
 	return cluster.Client
 }
+
+// ClusterCache returns the cache of the cluster, nil once the cluster is gone, which caches nothing
 func (k *Kubectl) ClusterCache() *ristretto.Cache[string, any] {
-	cache := Clusters().GetClusterById(k.ID).Cache
-	return cache
+	cluster := Clusters().GetClusterById(k.ID)
+	if cluster == nil {
+		return nil
+	}
+	return cluster.Cache
 }
 func (k *Kubectl) DynamicClient() *dynamic.DynamicClient {
+	if ic := k.Statement.impersonated; ic != nil {
+		return ic.dynamicClient
+	}
 	cluster := Clusters().GetClusterById(k.ID)
+	if cluster == nil {
+		client, _ := dynamic.NewForConfig(unavailableConfig(k.ID))
+		return client
+	}
 	return cluster.DynamicClient
 }
 func (k *Kubectl) parentCluster() *ClusterInst {
@@ -0,0 +1,140 @@
+package kom
+
+import (
+	"math/rand"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+)
+
+// RetryPolicy controls how Update, Patch, Mutate and the Applier retry failed requests
+// Throttling (429) and server errors (5xx) are retried with exponential backoff and jitter.
+// Conflicts (409) are retried only where the change can be re-applied on the latest object: Mutate and the Applier.
+type RetryPolicy struct {
+	Attempts   int           // Total attempts including the first, at least 1
+	Backoff    time.Duration // Delay before the first retry, doubled after every retry
+	MaxBackoff time.Duration // Upper bound of the delay
+	Jitter     float64       // Random extra delay, as a fraction of the delay
+	Conflicts  bool          // Whether conflicts are retried
+}
+
+// DefaultRetryPolicy is used by WithRetry without arguments and by Mutate
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:   5,
+	Backoff:    200 * time.Millisecond,
+	MaxBackoff: 5 * time.Second,
+	Jitter:     0.5,
+	Conflicts:  true,
+}
+
+// WithRetry retries the following mutating operations, using DefaultRetryPolicy when no policy is given
+//
+// Example:
+// kom.DefaultCluster().WithRetry().Resource(&deploy).Namespace("default").Name("nginx").Ctl().Scale(3)
+func (k *Kubectl) WithRetry(policy ...RetryPolicy) *Kubectl {
+	tx := k.getInstance()
+	p := DefaultRetryPolicy
+	if len(policy) > 0 {
+		p = policy[0]
+	}
+	tx.Statement.Retry = &p
+	return tx
+}
+
+// Mutate reads the latest object into dest, applies mutate and updates it
+// On a conflict the object is read again and mutate is applied again.
+// The retry policy set by WithRetry is used, or DefaultRetryPolicy.
+//
+// Example:
+// var deploy v1.Deployment
+//
+//	err := kom.DefaultCluster().Resource(&deploy).Namespace("default").Name("nginx").Mutate(&deploy, func(obj interface{}) error {
+//		deploy.Spec.Replicas = utils.Int32Ptr(3)
+//		return nil
+//	}).Error
+func (k *Kubectl) Mutate(dest interface{}, mutate func(obj interface{}) error) *Kubectl {
+	tx := k.getInstance()
+	policy := DefaultRetryPolicy
+	if tx.Statement.Retry != nil {
+		policy = *tx.Statement.Retry
+	}
+	policy.Conflicts = true
+	// A cached read would repeat the conflict
+	tx.Statement.CacheTTL = 0
+
+	tx.Error = tx.retry(&policy, true, func() error {
+		tx.Statement.Dest = dest
+		if err := tx.Callback().Get().Execute(tx); err != nil {
+			return err
+		}
+		if err := mutate(dest); err != nil {
+			return err
+		}
+		tx.Statement.Dest = dest
+		return tx.Callback().Update().Execute(tx)
+	})
+	return tx
+}
+
+// retry runs op until it succeeds, fails with an error that is not retried, or the attempts are used up
+// conflicts must only be true when op re-reads the object, otherwise a retry repeats the same conflict.
+func (k *Kubectl) retry(policy *RetryPolicy, conflicts bool, op func() error) error {
+	if policy == nil || policy.Attempts <= 1 {
+		return op()
+	}
+	delay := policy.Backoff
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= policy.Attempts {
+			return err
+		}
+		conflict := conflicts && policy.Conflicts && apierrors.IsConflict(err)
+		if !conflict && !isTransientError(err) {
+			return err
+		}
+
+		wait := delay
+		if seconds, ok := apierrors.SuggestsClientDelay(err); ok && time.Duration(seconds)*time.Second > wait {
+			// Honour Retry-After of throttled requests
+			wait = time.Duration(seconds) * time.Second
+		}
+		if policy.Jitter > 0 && wait > 0 {
+			wait += time.Duration(rand.Float64() * policy.Jitter * float64(wait))
+		}
+		klog.V(4).Infof("retry %s/%s on cluster %s in %s, attempt %d: %v", k.Statement.Namespace, k.Statement.Name, k.ID, wait, attempt, err)
+
+		var done <-chan struct{}
+		if k.Statement.Context != nil {
+			done = k.Statement.Context.Done()
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-done:
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		delay *= 2
+		if policy.MaxBackoff > 0 && delay > policy.MaxBackoff {
+			delay = policy.MaxBackoff
+		}
+	}
+}
+
+// isTransientError reports whether a request failed because of throttling or a server side problem
+func isTransientError(err error) bool {
+	if apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsInternalError(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsUnexpectedServerError(err) {
+		return true
+	}
+	if status, ok := err.(apierrors.APIStatus); ok {
+		return status.Status().Code >= 500
+	}
+	return false
+}
@@ -208,7 +208,10 @@ func (k *Kubectl) Watch(dest interface{}, opt ...metav1.ListOptions) *Kubectl {
 func (k *Kubectl) Update(dest interface{}) *Kubectl {
 	tx := k.getInstance()
 	tx.Statement.Dest = dest
-	tx.Error = tx.Callback().Update().Execute(tx)
+	// The object carries its resourceVersion, so a conflict would repeat on retry
+	tx.Error = tx.retry(tx.Statement.Retry, false, func() error {
+		return tx.Callback().Update().Execute(tx)
+	})
 	return tx
 }
 func (k *Kubectl) Delete() *Kubectl {
@@ -227,7 +230,9 @@ func (k *Kubectl) Patch(dest interface{}, pt types.PatchType, data string) *Kube
 	tx.Statement.Dest = dest
 	tx.Statement.PatchData = data
 	tx.Statement.PatchType = pt
-	tx.Error = tx.Callback().Patch().Execute(tx)
+	tx.Error = tx.retry(tx.Statement.Retry, false, func() error {
+		return tx.Callback().Patch().Execute(tx)
+	})
 	return tx
 }
 
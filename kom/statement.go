@@ -3,6 +3,7 @@ package kom
 import (
 	"context"
 	"io"
+	"sync"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
@@ -12,12 +13,15 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
 )
 
 type Statement struct {
 	*Kubectl            `json:"Kubectl,omitempty"`  // Base configuration
 	RowsAffected        int64                       `json:"rowsAffected,omitempty"`        // Number of affected rows
+	StartTime           time.Time                   `json:"startTime,omitempty"`           // Time the operation started executing
+	Duration            time.Duration               `json:"duration,omitempty"`            // Time spent in before and main callbacks
 	TotalCount          *int64                      `json:"totalCount,omitempty"`          // Total count for queries, used for pagination. Only effective in List query methods.
 	AllNamespace        bool                        `json:"allNamespace,omitempty"`        // All namespaces
 	Namespace           string                      `json:"namespace,omitempty"`           // Resource namespace
@@ -31,6 +35,7 @@ type Statement struct {
 	Dest                interface{}                 `json:"dest,omitempty"`                // Destination object for results, typically a struct pointer
 	PatchType           types.PatchType             `json:"patchType,omitempty"`           // PATCH type
 	PatchData           string                      `json:"patchData,omitempty"`           // PATCH data
+	SubResource         string                      `json:"subResource,omitempty"`         // Subresource to patch, e.g. ephemeralcontainers
 	RemoveManagedFields bool                        `json:"removeManagedFields,omitempty"` // Whether to remove managed fields
 	useCustomGVK        bool                        `json:"-"`                             // If GVK is set via CRD method, force its use and skip automatic GVK resolution
 	ContainerName       string                      `json:"containerName,omitempty"`       // Container name, used for container log operations
@@ -38,13 +43,30 @@ type Statement struct {
 	Args                []string                    `json:"args,omitempty"`                // Container command arguments
 	PodLogOptions       *v1.PodLogOptions           `json:"-" `                            // Used for getting container logs
 	Stdin               io.Reader                   `json:"-" `                            // Set input
+	Stdout              io.Writer                   `json:"-"`                             // Receives the stdout of an exec instead of Dest
 	Filter              Filter                      `json:"filter,omitempty"`
 	StdoutCallback      func(data []byte) error     `json:"-"`
 	StderrCallback      func(data []byte) error     `json:"-"`
-	CacheTTL            time.Duration               `json:"cacheTTL,omitempty"`    // Cache duration
-	ForceDelete         bool                        `json:"forceDelete,omitempty"` // Force delete flag
+	CacheTTL            time.Duration               `json:"cacheTTL,omitempty"`        // Cache duration
+	CacheStatus         string                      `json:"cacheStatus,omitempty"`     // Cache lookup result: hit, miss, or empty when not cached
+	ForceDelete         bool                        `json:"forceDelete,omitempty"`     // Force delete flag
+	Evict               bool                        `json:"evict,omitempty"`           // Delete a pod through the eviction API, which respects PodDisruptionBudgets
+	PortForward         *PortForwardRequest         `json:"-"`                         // Port forward to start
+	Terminal            *Terminal                   `json:"-"`                         // Interactive session of a stream exec
+	ExecTimeout         time.Duration               `json:"execTimeout,omitempty"`     // Timeout of an exec
+	ExecOutputLimit     int                         `json:"execOutputLimit,omitempty"` // Bytes of stdout and stderr kept by an exec, 0 keeps all
+	FileHelperImage     string                      `json:"fileHelperImage,omitempty"` // Image of the helper container used by file operations when the container lacks their commands
+	Retry               *RetryPolicy                `json:"retry,omitempty"`           // Retry policy of mutating operations
+	Impersonation       *rest.ImpersonationConfig   `json:"-"`                         // Identity to impersonate for this operation
+	impersonated        *impersonatedClient         `json:"-"`                         // Clients bound to the impersonated identity
+	Settings            sync.Map                    `json:"-"`                         // Values shared between the callbacks of one operation
 }
 
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
 type Filter struct {
 	Columns    []string    `json:"columns,omitempty"`
 	Conditions []Condition `json:"condition,omitempty"` // xx=?
@@ -65,6 +87,18 @@ type Condition struct {
 	ValueType string      // number, string, bool, time
 }
 
+// SetCacheStatus records the cache lookup result of a cached query
+func (s *Statement) SetCacheStatus(hit bool) {
+	switch {
+	case s.CacheTTL <= 0:
+		s.CacheStatus = ""
+	case hit:
+		s.CacheStatus = CacheHit
+	default:
+		s.CacheStatus = CacheMiss
+	}
+}
+
 func (s *Statement) ParseGVKs(gvks []schema.GroupVersionKind, versions ...string) *Statement {
 
 	s.GVR = schema.GroupVersionResource{}
@@ -1,7 +1,6 @@
 package kom
 
 import (
-	"context"
 	"strings"
 	"time"
 
@@ -22,27 +21,30 @@ type status struct {
 
 func (s *status) APIResources() []*metav1.APIResource {
 	cluster := s.kubectl.parentCluster()
-	return cluster.apiResources
+	return cluster.getAPIResources()
 }
 func (s *status) CRDList() []*unstructured.Unstructured {
 	cluster := s.kubectl.parentCluster()
-	return cluster.crdList
+	return cluster.getCRDList()
 }
 func (s *status) Docs() *doc.Docs {
 	cluster := s.kubectl.parentCluster()
-	return cluster.docs
+	return cluster.getDocs()
 }
 func (s *status) ServerVersion() *version.Info {
 	cluster := s.kubectl.parentCluster()
-	return cluster.serverVersion
+	return cluster.getServerVersion()
 }
 func (s *status) DescriberMap() map[schema.GroupKind]describe.ResourceDescriber {
+	if ic := s.kubectl.Statement.impersonated; ic != nil {
+		return ic.DescriberMap()
+	}
 	cluster := s.kubectl.parentCluster()
-	return cluster.describerMap
+	return cluster.getDescriberMap()
 }
 func (s *status) OpenAPISchema() *openapi_v2.Document {
 	cluster := s.kubectl.parentCluster()
-	return cluster.openAPISchema
+	return cluster.getOpenAPISchema()
 }
 
 // Get version information
@@ -65,7 +67,7 @@ func (k *Kubectl) getOpenAPISchema() *openapi_v2.Document {
 
 func (k *Kubectl) initializeCRDList(ttl time.Duration) []*unstructured.Unstructured {
 	cache, err := utils.GetOrSetCache(k.ClusterCache(), "crdList", ttl, func() (ret []*unstructured.Unstructured, err error) {
-		crdList, err := k.listResources(context.TODO(), "CustomResourceDefinition", "")
+		crdList, err := k.listResources(k.Statement.Context, "CustomResourceDefinition", "")
 		return crdList, err
 	})
 	if err != nil {
@@ -204,7 +204,7 @@ func (u *tools) GetGVK(gvks []schema.GroupVersionKind, versions ...string) (gvk
 // APIResource includes CRD content
 func (u *tools) FindGVKByTableNameInApiResources(tableName string) *schema.GroupVersionKind {
 
-	for _, resource := range u.kubectl.parentCluster().apiResources {
+	for _, resource := range u.kubectl.Status().APIResources() {
 		// Compare table name with resource Name or Kind
 		if resource.Name == tableName || resource.Kind == tableName || resource.SingularName == tableName ||
 			slice.Contain(resource.ShortNames, tableName) {
@@ -222,7 +222,7 @@ func (u *tools) FindGVKByTableNameInApiResources(tableName string) *schema.Group
 // FindGVKByTableNameInCRDList finds the corresponding GVK from the CRD list for a table name
 func (u *tools) FindGVKByTableNameInCRDList(tableName string) *schema.GroupVersionKind {
 
-	for _, crd := range u.kubectl.parentCluster().crdList {
+	for _, crd := range u.kubectl.Status().CRDList() {
 		// Get the names field under "spec" from the CRD object
 		specNames, found, err := unstructured.NestedMap(crd.Object, "spec", "names")
 		if err != nil || !found {
@@ -262,7 +262,7 @@ func (u *tools) FindGVKByTableNameInCRDList(tableName string) *schema.GroupVersi
 	return nil // No match found
 }
 func (u *tools) ListAvailableTableNames() (names []string) {
-	for _, resource := range u.kubectl.parentCluster().apiResources {
+	for _, resource := range u.kubectl.Status().APIResources() {
 		// Compare table name with resource Name or Kind
 		names = append(names, strings.ToLower(resource.Kind))
 		for _, name := range resource.ShortNames {
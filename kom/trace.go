@@ -0,0 +1,156 @@
+package kom
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"k8s.io/client-go/rest"
+)
+
+// Span is a unit of traced work
+// Implement it with an adapter around the tracing library in use, e.g. an OpenTelemetry trace.Span.
+type Span interface {
+	SetAttributes(attrs map[string]any)
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts spans as children of the span found in ctx
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// HeaderInjector is optionally implemented by a Tracer to propagate the span of a request to the API server
+// e.g. with an OpenTelemetry TextMapPropagator writing the traceparent header.
+type HeaderInjector interface {
+	Inject(ctx context.Context, header http.Header)
+}
+
+// Span attribute keys
+const (
+	AttrCluster      = "kom.cluster"
+	AttrOperation    = "kom.operation"
+	AttrGroup        = "kom.group"
+	AttrVersion      = "kom.version"
+	AttrResource     = "kom.resource"
+	AttrNamespace    = "kom.namespace"
+	AttrName         = "kom.name"
+	AttrWhereCount   = "kom.where.count"
+	AttrRowsAffected = "kom.rows_affected"
+	AttrCacheStatus  = "kom.cache"
+	AttrImpersonate  = "kom.impersonate"
+)
+
+type tracerHolder struct {
+	tracer Tracer
+}
+
+var currentTracer atomic.Pointer[tracerHolder]
+
+// SetTracer traces every operation on every cluster, nil disables tracing
+// Ctl() helpers that send several requests, such as Drain, Rollout().Undo or Scale, get a span around their operations.
+// Spans are children of the span in the operation context, set with WithContext.
+func SetTracer(t Tracer) {
+	if t == nil {
+		currentTracer.Store(nil)
+		return
+	}
+	currentTracer.Store(&tracerHolder{tracer: t})
+}
+
+func getTracer() Tracer {
+	if h := currentTracer.Load(); h != nil {
+		return h.tracer
+	}
+	return nil
+}
+
+// startSpan starts a span for the statement, returns nil when tracing is disabled
+func (k *Kubectl) startSpan(name string) (context.Context, Span) {
+	ctx := k.Statement.Context
+	tracer := getTracer()
+	if tracer == nil {
+		return ctx, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, span := tracer.Start(ctx, name)
+	span.SetAttributes(k.spanAttributes())
+	return ctx, span
+}
+
+// spanAttributes describes the target of the statement
+func (k *Kubectl) spanAttributes() map[string]any {
+	stmt := k.Statement
+	attrs := map[string]any{
+		AttrCluster:    k.ID,
+		AttrGroup:      stmt.GVR.Group,
+		AttrVersion:    stmt.GVR.Version,
+		AttrResource:   stmt.GVR.Resource,
+		AttrNamespace:  stmt.Namespace,
+		AttrName:       stmt.Name,
+		AttrWhereCount: len(stmt.Filter.Conditions),
+	}
+	if stmt.Impersonation != nil {
+		attrs[AttrImpersonate] = stmt.Impersonation.UserName
+	}
+	return attrs
+}
+
+// endSpan records the outcome of the statement on span
+func (k *Kubectl) endSpan(span Span, err error) {
+	if span == nil {
+		return
+	}
+	attrs := map[string]any{AttrRowsAffected: k.Statement.RowsAffected}
+	if k.Statement.CacheStatus != "" {
+		attrs[AttrCacheStatus] = k.Statement.CacheStatus
+	}
+	span.SetAttributes(attrs)
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// traceHelper runs a Ctl() helper in its own span, operations started by fn become its children
+func (k *Kubectl) traceHelper(name string, fn func() error) error {
+	// Never change the context of the shared cluster instance
+	if getTracer() == nil || k.clone > 0 {
+		return fn()
+	}
+	ctx, span := k.startSpan(name)
+	origin := k.Statement.Context
+	k.Statement.Context = ctx
+	err := fn()
+	k.Statement.Context = origin
+	k.endSpan(span, err)
+	return err
+}
+
+// tracingTransport propagates the span of a request to the API server
+type tracingTransport struct {
+	next http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	injector, ok := getTracer().(HeaderInjector)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	injector.Inject(req.Context(), req.Header)
+	return t.next.RoundTrip(req)
+}
+
+// withTracingTransport returns a copy of config whose requests carry the span of their context
+// The caller's config is left alone, it may be registered again or used by other clients.
+func withTracingTransport(config *rest.Config) *rest.Config {
+	config = rest.CopyConfig(config)
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &tracingTransport{next: rt}
+	})
+	return config
+}
@@ -51,18 +51,21 @@ func ExecHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToo
 	klog.V(6).Infof("Executing command in pod %s/%s container %s: %v %v", meta.Namespace, meta.Name, containerName, command, argsVal)
 
 	// 执行命令
-	var execResult string
-	err = kom.Cluster(meta.Cluster).WithContext(ctx).
+	result, err := kom.Cluster(meta.Cluster).WithContext(ctx).
 		Namespace(meta.Namespace).
 		Name(meta.Name).
 		Ctl().Pod().
 		ContainerName(containerName).
 		Command(command, argsVal...).
-		Execute(&execResult).Error
+		Run()
 
 	if err != nil {
 		return nil, fmt.Errorf("command execution failed: %v", err)
 	}
+	if !result.Success() {
+		// 非零退出码作为工具错误返回，附带输出 / A non-zero exit is a tool error, with the output attached
+		return tools.ErrorResult(fmt.Errorf("command execution failed: exit code %d\n%s%s", result.ExitCode, result.Stdout, result.Stderr)), nil
+	}
 
-	return tools.TextResult(execResult, meta)
+	return tools.TextResult(string(result.Stdout), meta)
 }
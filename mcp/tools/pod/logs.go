@@ -2,14 +2,13 @@ package pod
 
 import (
 	"context"
-	"io"
+	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/weibaohui/kom/kom"
 	"github.com/weibaohui/kom/mcp/tools"
 	"github.com/weibaohui/kom/mcp/tools/metadata"
-	"github.com/weibaohui/kom/utils"
-	v1 "k8s.io/api/core/v1"
 )
 
 // GetPodLogsTool creates a tool for getting Pod logs
@@ -20,9 +19,11 @@ func GetPodLogsTool() mcp.Tool {
 		mcp.WithString("cluster", mcp.Description("The cluster runs the pod")),
 		mcp.WithString("namespace", mcp.Description("The namespace of the pod")),
 		mcp.WithString("name", mcp.Description("The name of the pod")),
-		mcp.WithNumber("container", mcp.Description("Name of the container in the pod (must be specified if there are more than one container in Pod, only one container could use empty string)")),
+		mcp.WithString("container", mcp.Description("Name of the container in the pod (must be specified if there are more than one container in Pod, only one container could use empty string)")),
 		mcp.WithNumber("tail", mcp.Description("Number of lines from the end of the logs to show (default 100)")),
+		mcp.WithNumber("sinceSeconds", mcp.Description("Only return logs newer than this many seconds")),
 		mcp.WithBoolean("previous", mcp.Description("Whether to get logs from the previous container (default false)")),
+		mcp.WithBoolean("timestamps", mcp.Description("Whether to prefix every line with its timestamp (default false)")),
 	)
 }
 
@@ -42,22 +43,26 @@ func GetPodLogsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.C
 	if containerNameVal, ok := request.Params.Arguments["container"].(string); ok {
 		containerName = containerNameVal
 	}
-	var stream io.ReadCloser
-	opt := &v1.PodLogOptions{}
-	opt.TailLines = utils.Ptr(tailLines)
+	opts := kom.NewLogOptions().Tail(tailLines)
+	if sinceSeconds, ok := request.Params.Arguments["sinceSeconds"].(float64); ok && sinceSeconds > 0 {
+		opts.Since(time.Duration(sinceSeconds) * time.Second)
+	}
 	// 设置是否获取上一个容器的日志
 	if previous, ok := request.Params.Arguments["previous"].(bool); ok && previous {
-		opt.Previous = true
+		opts.Previous()
 	}
-	err = kom.Cluster(meta.Cluster).WithContext(ctx).Namespace(meta.Namespace).Name(meta.Name).Ctl().Pod().ContainerName(containerName).GetLogs(&stream, opt).Error
-	if err != nil {
-		return nil, err
+	if timestamps, ok := request.Params.Arguments["timestamps"].(bool); ok && timestamps {
+		opts.Timestamps()
 	}
-	// Read all log content
-	var logs []byte
-	logs, err = io.ReadAll(stream)
+	var logs strings.Builder
+	err = kom.Cluster(meta.Cluster).WithContext(ctx).Namespace(meta.Namespace).Name(meta.Name).Ctl().Pod().ContainerName(containerName).
+		StreamLogs(opts, func(line []byte) error {
+			logs.Write(line)
+			logs.WriteByte('\n')
+			return nil
+		}).Error
 	if err != nil {
 		return nil, err
 	}
-	return tools.TextResult(string(logs), meta)
+	return tools.TextResult(logs.String(), meta)
 }
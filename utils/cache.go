@@ -8,27 +8,34 @@ import (
 )
 
 func GetOrSetCache[T any](cache *ristretto.Cache[string, any], cacheKey string, ttl time.Duration, queryFunc func() (T, error)) (T, error) {
+	result, _, err := GetOrSetCacheHit(cache, cacheKey, ttl, queryFunc)
+	return result, err
+}
+
+// GetOrSetCacheHit works like GetOrSetCache and also reports whether the result came from the cache
+func GetOrSetCacheHit[T any](cache *ristretto.Cache[string, any], cacheKey string, ttl time.Duration, queryFunc func() (T, error)) (T, bool, error) {
 	var zero T
 
 	// If TTL parameter is not set, no caching is needed, execute query method directly
 	if ttl <= 0 {
-		return queryFunc()
+		result, err := queryFunc()
+		return result, false, err
 	}
 	// Check if cache hit
 	if v, found := cache.Get(cacheKey); found {
 		klog.V(5).Infof("cache hit cacheKey= %s", cacheKey)
-		return v.(T), nil
+		return v.(T), true, nil
 	}
 
 	// Cache miss, execute query method
 	result, err := queryFunc()
 	if err != nil {
-		return zero, err
+		return zero, false, err
 	}
 
 	// Set cache and return result
 	cache.SetWithTTL(cacheKey, result, 100, ttl)
 	cache.Wait()
 
-	return result, nil
+	return result, false, nil
 }